@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-bitfield"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+)
+
+// 扇区在链上的状态
+// the state of a sector on chain, taken from the partition bitfields
+const (
+	chainNone       = "none"
+	chainLive       = "live"
+	chainFaulty     = "faulty"
+	chainTerminated = "terminated"
+	// chainUnassigned is a sector in the sectors AMT that no partition holds
+	chainUnassigned = "unassigned"
+)
+
+// auditExpected 是本地状态允许的链上状态, 不在表里的本地状态 (中间状态) 不检查
+// auditExpected maps a local sealing state to the chain states it is consistent with. Transitional
+// states such as CommitWait or Terminating are not in the table and are never reported. Lotus leaves
+// a sector faulted by a missed WindowPoSt in Proving, faulty sectors are live on chain.
+var auditExpected = map[api.SectorState][]string{
+	"WaitDeals":            {chainNone},
+	"AddPiece":             {chainNone},
+	"Packing":              {chainNone},
+	"GetTicket":            {chainNone},
+	"PreCommit1":           {chainNone},
+	"PreCommit2":           {chainNone},
+	"PreCommitting":        {chainNone},
+	"PreCommitWait":        {chainNone},
+	"WaitSeed":             {chainNone},
+	"Committing":           {chainNone},
+	"SubmitCommit":         {chainNone},
+	"AddPieceFailed":       {chainNone},
+	"SealPreCommit1Failed": {chainNone},
+	"SealPreCommit2Failed": {chainNone},
+	"PreCommitFailed":      {chainNone},
+	"ComputeProofFailed":   {chainNone},
+	"PackingFailed":        {chainNone},
+	"FinalizeSector":       {chainLive, chainFaulty},
+	"FinalizeFailed":       {chainLive, chainFaulty},
+	"Proving":              {chainLive, chainFaulty},
+	"Faulty":               {chainLive, chainFaulty},
+	"FaultReported":        {chainLive, chainFaulty},
+	"FaultedFinal":         {chainTerminated, chainNone},
+	"TerminateFinality":    {chainTerminated, chainNone},
+	"Removing":             {chainTerminated, chainNone},
+	"RemoveFailed":         {chainTerminated, chainNone},
+	"Removed":              {chainTerminated, chainNone},
+}
+
+// auditSector 是本地和链上不一致的扇区
+// auditSector is a sector whose local and chain states disagree
+type auditSector struct {
+	Sector abi.SectorNumber
+	// Kind is local_only, chain_only or mismatched
+	Kind  string
+	Local api.SectorState
+	Chain string
+}
+
+// chainSectorStates 根据 partition 的 bitfield 返回每个扇区的链上状态
+// chainSectorStates returns the chain state of every sector in the sectors AMT or in a partition
+func chainSectorStates(onChain []*miner.SectorOnChainInfo, parts *minerPartitions) (map[abi.SectorNumber]string, error) {
+	states := map[abi.SectorNumber]string{}
+	for _, sector := range onChain {
+		states[sector.SectorNumber] = chainUnassigned
+	}
+	for _, partitions := range parts.Partitions {
+		for _, partition := range partitions {
+			// Faulty 是 Live 的子集, Live 是 All 的子集, 后写的覆盖前面的
+			// faulty sectors are live and live sectors are in all, the narrowest set is written last
+			for _, set := range []struct {
+				state   string
+				sectors bitfield.BitField
+			}{
+				{chainTerminated, partition.AllSectors},
+				{chainLive, partition.LiveSectors},
+				{chainFaulty, partition.FaultySectors},
+			} {
+				state := set.state
+				err := set.sectors.ForEach(func(sector uint64) error {
+					states[abi.SectorNumber(sector)] = state
+					return nil
+				})
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return states, nil
+}
+
+// auditSectors 比较本地扇区状态和链上状态
+// auditSectors compares the local sealing state of every sector with its chain state
+func auditSectors(sectors []api.SectorInfo, onChain []*miner.SectorOnChainInfo, parts *minerPartitions) ([]auditSector, error) {
+	chainStates, err := chainSectorStates(onChain, parts)
+	if err != nil {
+		return nil, err
+	}
+	var found []auditSector
+	local := map[abi.SectorNumber]bool{}
+	for _, detail := range sectors {
+		local[detail.SectorID] = true
+		chainState, ok := chainStates[detail.SectorID]
+		if !ok {
+			chainState = chainNone
+		}
+		expected, checked := auditExpected[detail.State]
+		if !checked {
+			continue
+		}
+		consistent := false
+		for _, state := range expected {
+			if state == chainState {
+				consistent = true
+			}
+		}
+		if consistent {
+			continue
+		}
+		kind := "mismatched"
+		if chainState == chainNone {
+			kind = "local_only"
+		}
+		found = append(found, auditSector{Sector: detail.SectorID, Kind: kind, Local: detail.State, Chain: chainState})
+	}
+	for sector, chainState := range chainStates {
+		if !local[sector] && chainState != chainTerminated {
+			found = append(found, auditSector{Sector: sector, Kind: "chain_only", Chain: chainState})
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Sector < found[j].Sector })
+	return found, nil
+}
+
+// generateAudit 输出本地和链上不一致的扇区数
+// generateAudit prints the number of local only, chain only and mismatched sectors, and every sector
+// found when SECTOR_DETAILS is set
+func generateAudit(minerId address.Address, minerHost string, sectors []api.SectorInfo, onChain []*miner.SectorOnChainInfo, parts *minerPartitions) error {
+	found, err := auditSectors(sectors, onChain, parts)
+	if err != nil {
+		return err
+	}
+	count := map[string]int{}
+	for _, s := range found {
+		count[s.Kind]++
+	}
+	fmt.Println("# HELP lotus_miner_sector_audit number of sectors whose local and chain states disagree")
+	fmt.Println("# TYPE lotus_miner_sector_audit gauge")
+	for _, kind := range []string{"local_only", "chain_only", "mismatched"} {
+		fmt.Print("lotus_miner_sector_audit { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", kind=", `"`, kind, `"`, " } ", count[kind], "\n")
+	}
+	if !Config.SectorDetails {
+		return nil
+	}
+	fmt.Println("# HELP lotus_miner_sector_audit_sector sector whose local and chain states disagree")
+	fmt.Println("# TYPE lotus_miner_sector_audit_sector gauge")
+	for _, s := range found {
+		fmt.Print("lotus_miner_sector_audit_sector { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", sector_id=", `"`, s.Sector, `"`, ", kind=", `"`, s.Kind, `"`, ", local_state=", `"`, s.Local, `"`, ", chain_state=", `"`, s.Chain, `"`, " } 1\n")
+	}
+	return nil
+}
+
+// auditCmd 列出本地和链上不一致的扇区, 扇区状态总是重新获取而不用缓存
+// auditCmd lists the sectors whose local and chain states disagree. The sector statuses are always
+// fetched from the miner, the sector cache is not used.
+func auditCmd(args []string) error {
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	minerId, err := storageMiner.ActorAddress(ctx)
+	if err != nil {
+		return err
+	}
+	sectors, err := loadSectors(ctx)
+	if err != nil {
+		return err
+	}
+	head, err := fullNode.ChainHead(ctx)
+	if err != nil {
+		return err
+	}
+	onChain, err := fullNode.StateMinerSectors(ctx, minerId, nil, head.Key())
+	if err != nil {
+		return fmt.Errorf("StateMinerSectors: %w", err)
+	}
+	parts, err := loadMinerPartitions(ctx, minerId, head.Key())
+	if err != nil {
+		return err
+	}
+	found, err := auditSectors(sectors, onChain, parts)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SECTOR\tKIND\tLOCAL STATE\tCHAIN STATE")
+	for _, s := range found {
+		local := string(s.Local)
+		if local == "" {
+			local = "-"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", s.Sector, s.Kind, local, s.Chain)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Println(len(sectors), "local sectors checked,", len(found), "inconsistent")
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/filecoin-project/go-bitfield"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+)
+
+func TestAuditSectors(t *testing.T) {
+	// 1-4 are live, 2 of them faulty, 5 is terminated, 6 is only in the sectors AMT
+	parts := &minerPartitions{
+		Partitions: [][]api.Partition{{{
+			AllSectors:    bitfield.NewFromSet([]uint64{1, 2, 3, 4, 5}),
+			LiveSectors:   bitfield.NewFromSet([]uint64{1, 2, 3, 4}),
+			ActiveSectors: bitfield.NewFromSet([]uint64{1, 3, 4}),
+			FaultySectors: bitfield.NewFromSet([]uint64{2}),
+		}}},
+	}
+	var onChain []*miner.SectorOnChainInfo
+	for _, sector := range []abi.SectorNumber{1, 2, 3, 4, 5, 6} {
+		onChain = append(onChain, &miner.SectorOnChainInfo{SectorNumber: sector})
+	}
+	tests := []struct {
+		name  string
+		local map[abi.SectorNumber]api.SectorState
+		found []auditSector
+	}{
+		{"consistent", map[abi.SectorNumber]api.SectorState{1: "Proving", 2: "Proving", 3: "FinalizeSector", 4: "FaultReported", 5: "Removed", 6: "CommitWait"}, nil},
+		{"faulty sector still proving", map[abi.SectorNumber]api.SectorState{2: "Proving"}, []auditSector{
+			{Sector: 1, Kind: "chain_only", Chain: chainLive},
+			{Sector: 3, Kind: "chain_only", Chain: chainLive},
+			{Sector: 4, Kind: "chain_only", Chain: chainLive},
+			{Sector: 6, Kind: "chain_only", Chain: chainUnassigned},
+		}},
+		{"local only", map[abi.SectorNumber]api.SectorState{1: "Proving", 2: "Proving", 3: "Proving", 4: "Proving", 6: "CommitWait", 7: "Proving", 8: "PreCommit1"}, []auditSector{
+			{Sector: 7, Kind: "local_only", Local: "Proving", Chain: chainNone},
+		}},
+		{"mismatched", map[abi.SectorNumber]api.SectorState{1: "PreCommit1", 2: "Proving", 3: "Proving", 4: "Proving", 5: "Proving", 6: "Proving"}, []auditSector{
+			{Sector: 1, Kind: "mismatched", Local: "PreCommit1", Chain: chainLive},
+			{Sector: 5, Kind: "mismatched", Local: "Proving", Chain: chainTerminated},
+			{Sector: 6, Kind: "mismatched", Local: "Proving", Chain: chainUnassigned},
+		}},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			var sectors []api.SectorInfo
+			for sector, state := range test.local {
+				sectors = append(sectors, api.SectorInfo{SectorID: sector, State: state})
+			}
+			found, err := auditSectors(sectors, onChain, parts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := fmt.Sprint(found), fmt.Sprint(test.found); got != want {
+				t.Errorf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/ipfs/go-cid"
+)
+
+// 只扫描至少有这么多确认的 tipset, 避免链重组导致重复计数
+// only tipsets with at least this many confirmations are scanned so that a reorg can't count a message twice
+const messageConfidence = abi.ChainEpoch(5)
+
+// chainMessage 是矿工地址发送并已上链的消息
+// chainMessage is a message sent from one of the miner addresses that landed on chain
+type chainMessage struct {
+	Cid     cid.Cid
+	Message *types.Message
+	Receipt *types.MessageReceipt
+	Role    string
+	Method  string
+	// Height is the height of the tipset that included the message
+	Height abi.ChainEpoch
+	// BaseFee is the base fee the message was executed with, the ParentBaseFee of the tipset that
+	// included it
+	BaseFee types.BigInt
+}
+
+// scanChainMessages 扫描上次处理之后的 tipset
+// scanChainMessages walks the tipsets after state.ChainScanHeight and calls handle for every message
+// sent from one of the role addresses. A first run starts at CHAIN_SCAN_START_HEIGHT, or at the head
+// when it is not set, and a single run never scans more than CHAIN_SCAN_MAX_EPOCHS epochs.
+func scanChainMessages(ctx context.Context, minerId address.Address, head *types.TipSet, roles map[address.Address]string, state *State, handle func(*chainMessage)) error {
+	last := abi.ChainEpoch(state.ChainScanHeight)
+	if last == 0 {
+		if Config.ChainScanStartHeight > 0 {
+			last = abi.ChainEpoch(Config.ChainScanStartHeight) - 1
+		} else {
+			last = head.Height() - messageConfidence
+		}
+		// 记录起点, 下次运行从这里继续
+		// record the starting point so that the next run carries on from there
+		state.ChainScanHeight = int64(last)
+	}
+	to := head.Height() - messageConfidence
+	if to > last+abi.ChainEpoch(Config.ChainScanMaxEpochs) {
+		to = last + abi.ChainEpoch(Config.ChainScanMaxEpochs)
+	}
+
+	// 第 h 个 tipset 的父消息是在 h-1 上打包、在 h 上执行的消息
+	// the parent messages of the tipset at height h are the ones included at h-1 and executed at h
+	for h := last + 1; h <= to+1; h++ {
+		ts, err := fullNode.ChainGetTipSetByHeight(ctx, h, head.Key())
+		if err != nil {
+			return fmt.Errorf("ChainGetTipSetByHeight %d: %w", h, err)
+		}
+		if ts.Height() != h {
+			// null round
+			continue
+		}
+		parent, err := fullNode.ChainGetTipSet(ctx, ts.Parents())
+		if err != nil {
+			return fmt.Errorf("ChainGetTipSet %s: %w", ts.Parents(), err)
+		}
+		if parent.Height() <= last {
+			// already processed by a previous run
+			continue
+		}
+		blockCid := ts.Cids()[0]
+		messages, err := fullNode.ChainGetParentMessages(ctx, blockCid)
+		if err != nil {
+			return fmt.Errorf("ChainGetParentMessages %s: %w", blockCid, err)
+		}
+		receipts, err := fullNode.ChainGetParentReceipts(ctx, blockCid)
+		if err != nil {
+			return fmt.Errorf("ChainGetParentReceipts %s: %w", blockCid, err)
+		}
+		if len(messages) != len(receipts) {
+			return fmt.Errorf("tipset %d: %d messages but %d receipts", h, len(messages), len(receipts))
+		}
+		for i, message := range messages {
+			role, ok := roles[message.Message.From]
+			if !ok {
+				continue
+			}
+			handle(&chainMessage{
+				Cid:     message.Cid,
+				Message: message.Message,
+				Receipt: receipts[i],
+				Role:    role,
+				Method:  methodName(minerId, message.Message.To, message.Message.Method),
+				Height:  parent.Height(),
+				BaseFee: parent.Blocks()[0].ParentBaseFee,
+			})
+		}
+		state.ChainScanHeight = int64(parent.Height())
+	}
+	return nil
+}
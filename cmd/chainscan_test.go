@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/crypto"
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/ipfs/go-cid"
+)
+
+var (
+	testMiner, _  = address.NewIDAddress(1000)
+	testWorker, _ = address.NewIDAddress(1001)
+	testOther, _  = address.NewIDAddress(1002)
+)
+
+// fakeChain 是只实现了链扫描所需方法的 FullNode
+// fakeChain is a FullNode answering the calls made by scanChainMessages, every other method panics
+type fakeChain struct {
+	api.FullNode
+	byHeight map[abi.ChainEpoch]*types.TipSet
+	byKey    map[types.TipSetKey]*types.TipSet
+	byBlock  map[cid.Cid]*types.TipSet
+	// included maps a height to the messages included by the tipset at that height
+	included map[abi.ChainEpoch][]*types.Message
+}
+
+// newFakeChain builds a chain from 0 to head, the heights in nulls are null rounds. The tipset at
+// height h has a ParentBaseFee of 10*h and includes a message from the worker and from another address.
+func newFakeChain(head abi.ChainEpoch, nulls ...abi.ChainEpoch) *fakeChain {
+	c := &fakeChain{
+		byHeight: map[abi.ChainEpoch]*types.TipSet{},
+		byKey:    map[types.TipSetKey]*types.TipSet{},
+		byBlock:  map[cid.Cid]*types.TipSet{},
+		included: map[abi.ChainEpoch][]*types.Message{},
+	}
+	null := map[abi.ChainEpoch]bool{}
+	for _, h := range nulls {
+		null[h] = true
+	}
+	dummy, err := cid.Decode("bafyreicmaj5hhoy5mgqvamfhgexxyergw7hdeshizghodwkjg6qmpoco7i")
+	if err != nil {
+		panic(err)
+	}
+	var parent *types.TipSet
+	for h := abi.ChainEpoch(0); h <= head; h++ {
+		if null[h] {
+			continue
+		}
+		var parents []cid.Cid
+		if parent != nil {
+			parents = parent.Cids()
+		}
+		block := &types.BlockHeader{
+			Miner:                 testMiner,
+			Ticket:                &types.Ticket{VRFProof: []byte(fmt.Sprint(h))},
+			ElectionProof:         &types.ElectionProof{VRFProof: []byte(fmt.Sprint(h))},
+			Parents:               parents,
+			ParentWeight:          types.NewInt(uint64(h)),
+			Height:                h,
+			ParentStateRoot:       dummy,
+			ParentMessageReceipts: dummy,
+			Messages:              dummy,
+			BLSAggregate:          &crypto.Signature{Type: crypto.SigTypeBLS},
+			BlockSig:              &crypto.Signature{Type: crypto.SigTypeBLS},
+			Timestamp:             uint64(h) * 30,
+			ParentBaseFee:         types.NewInt(uint64(h) * 10),
+		}
+		ts, err := types.NewTipSet([]*types.BlockHeader{block})
+		if err != nil {
+			panic(err)
+		}
+		c.byHeight[h] = ts
+		c.byKey[ts.Key()] = ts
+		c.byBlock[block.Cid()] = ts
+		for i, from := range []address.Address{testWorker, testOther} {
+			c.included[h] = append(c.included[h], &types.Message{
+				From:       from,
+				To:         testMiner,
+				Nonce:      uint64(h)*2 + uint64(i),
+				GasLimit:   1000,
+				GasFeeCap:  types.NewInt(1000),
+				GasPremium: types.NewInt(1),
+			})
+		}
+		parent = ts
+	}
+	return c
+}
+
+func (c *fakeChain) ChainGetTipSetByHeight(ctx context.Context, h abi.ChainEpoch, tsk types.TipSetKey) (*types.TipSet, error) {
+	// 和 lotus 一样, 空块高度返回之前的 tipset
+	// like lotus, a null round gives the previous tipset
+	for ; h >= 0; h-- {
+		if ts, ok := c.byHeight[h]; ok {
+			return ts, nil
+		}
+	}
+	return nil, fmt.Errorf("no tipset at %d", h)
+}
+
+func (c *fakeChain) ChainGetTipSet(ctx context.Context, tsk types.TipSetKey) (*types.TipSet, error) {
+	ts, ok := c.byKey[tsk]
+	if !ok {
+		return nil, fmt.Errorf("unknown tipset %s", tsk)
+	}
+	return ts, nil
+}
+
+func (c *fakeChain) parentOf(blockCid cid.Cid) (*types.TipSet, error) {
+	ts, ok := c.byBlock[blockCid]
+	if !ok {
+		return nil, fmt.Errorf("unknown block %s", blockCid)
+	}
+	return c.ChainGetTipSet(context.Background(), ts.Parents())
+}
+
+func (c *fakeChain) ChainGetParentMessages(ctx context.Context, blockCid cid.Cid) ([]api.Message, error) {
+	parent, err := c.parentOf(blockCid)
+	if err != nil {
+		return nil, err
+	}
+	var messages []api.Message
+	for _, message := range c.included[parent.Height()] {
+		messages = append(messages, api.Message{Cid: message.Cid(), Message: message})
+	}
+	return messages, nil
+}
+
+func (c *fakeChain) ChainGetParentReceipts(ctx context.Context, blockCid cid.Cid) ([]*types.MessageReceipt, error) {
+	parent, err := c.parentOf(blockCid)
+	if err != nil {
+		return nil, err
+	}
+	var receipts []*types.MessageReceipt
+	for range c.included[parent.Height()] {
+		receipts = append(receipts, &types.MessageReceipt{GasUsed: 500})
+	}
+	return receipts, nil
+}
+
+// scan runs scanChainMessages with head at the given height and returns the inclusion heights of the
+// messages handled
+func (c *fakeChain) scan(t *testing.T, head abi.ChainEpoch, state *State) []abi.ChainEpoch {
+	fullNode = c
+	var heights []abi.ChainEpoch
+	roles := map[address.Address]string{testWorker: "worker"}
+	err := scanChainMessages(context.Background(), testMiner, c.byHeight[head], roles, state, func(msg *chainMessage) {
+		if msg.Message.From != testWorker {
+			t.Errorf("handled a message from %s", msg.Message.From)
+		}
+		if want := types.NewInt(uint64(msg.Height) * 10); !msg.BaseFee.Equals(want) {
+			t.Errorf("message included at %d has base fee %s, want %s", msg.Height, msg.BaseFee, want)
+		}
+		heights = append(heights, msg.Height)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return heights
+}
+
+func TestScanChainMessagesFirstRun(t *testing.T) {
+	Config = defaultEnv()
+	c := newFakeChain(40)
+	state := newState()
+
+	if heights := c.scan(t, 30, state); len(heights) != 0 {
+		t.Errorf("first run handled messages at %v", heights)
+	}
+	if state.ChainScanHeight != int64(30-messageConfidence) {
+		t.Fatalf("first run recorded height %d, want %d", state.ChainScanHeight, 30-messageConfidence)
+	}
+
+	heights := c.scan(t, 33, state)
+	want := []abi.ChainEpoch{26, 27, 28}
+	if fmt.Sprint(heights) != fmt.Sprint(want) {
+		t.Errorf("second run handled messages at %v, want %v", heights, want)
+	}
+	if state.ChainScanHeight != 28 {
+		t.Errorf("second run recorded height %d, want 28", state.ChainScanHeight)
+	}
+
+	if heights := c.scan(t, 33, state); len(heights) != 0 {
+		t.Errorf("rescan of the same head handled messages at %v", heights)
+	}
+}
+
+func TestScanChainMessagesStartHeight(t *testing.T) {
+	Config = defaultEnv()
+	Config.ChainScanStartHeight = 10
+	Config.ChainScanMaxEpochs = 5
+	c := newFakeChain(40)
+	state := newState()
+
+	heights := c.scan(t, 40, state)
+	want := []abi.ChainEpoch{10, 11, 12, 13, 14}
+	if fmt.Sprint(heights) != fmt.Sprint(want) {
+		t.Errorf("handled messages at %v, want %v", heights, want)
+	}
+	heights = c.scan(t, 40, state)
+	want = []abi.ChainEpoch{15, 16, 17, 18, 19}
+	if fmt.Sprint(heights) != fmt.Sprint(want) {
+		t.Errorf("next run handled messages at %v, want %v", heights, want)
+	}
+}
+
+func TestScanChainMessagesNullRounds(t *testing.T) {
+	Config = defaultEnv()
+	Config.ChainScanStartHeight = 10
+	c := newFakeChain(30, 13, 14, 20)
+	state := newState()
+
+	heights := c.scan(t, 30, state)
+	want := []abi.ChainEpoch{10, 11, 12, 15, 16, 17, 18, 19, 21, 22, 23, 24, 25}
+	if fmt.Sprint(heights) != fmt.Sprint(want) {
+		t.Errorf("handled messages at %v, want %v", heights, want)
+	}
+	if state.ChainScanHeight != 25 {
+		t.Errorf("recorded height %d, want 25", state.ChainScanHeight)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// commands 是 farcaster 的子命令, 没有子命令时输出指标
+// commands are the farcaster sub commands, without one farcaster prints the metrics
+var commands = map[string]func(args []string) error{
+	"audit":       auditCmd,
+	"deadlines":   deadlinesCmd,
+	"expirations": expirationsCmd,
+	"serve":       serveCmd,
+	"stuck":       stuckCmd,
+}
+
+func runCommand(name string, args []string) {
+	command, ok := commands[name]
+	if !ok {
+		var names []string
+		for n := range commands {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		fmt.Println("unknown command", name, "available commands:", names)
+		return
+	}
+	if err := command(args); err != nil {
+		fmt.Println(name, "error", err)
+	}
+}
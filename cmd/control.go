@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// controlAddress 是矿工的一个 control 地址
+// controlAddress is one of the miner control addresses
+type controlAddress struct {
+	Index int
+	// ID is the address as stored in the miner info, Key is the account key it resolves to
+	ID   address.Address
+	Key  address.Address
+	Role string
+}
+
+// resolveControlAddresses 解析矿工的全部 control 地址
+// resolveControlAddresses resolves every control address of the miner to its account key
+func resolveControlAddresses(ctx context.Context, ids []address.Address) ([]controlAddress, error) {
+	var controls []controlAddress
+	for i, id := range ids {
+		key, err := fullNode.StateAccountKey(ctx, id, types.EmptyTSK)
+		if err != nil {
+			return nil, fmt.Errorf("StateAccountKey %s: %w", id, err)
+		}
+		controls = append(controls, controlAddress{
+			Index: i,
+			ID:    id,
+			Key:   key,
+			Role:  "control" + strconv.Itoa(i),
+		})
+	}
+	return controls, nil
+}
+
+// hasAddress tells if the id or key form of an address is in the list
+func hasAddress(list []address.Address, id, key address.Address) bool {
+	for _, addr := range list {
+		if addr == id || addr == key {
+			return true
+		}
+	}
+	return false
+}
+
+// controlUses 返回 control 地址的用途, 与 lotus-miner actor control list 相同
+// controlUses returns what a control address is used for, the same way "lotus-miner actor control list" does
+func controlUses(addrConfig api.AddressConfig, control controlAddress) string {
+	uses := []string{"post"}
+	if hasAddress(addrConfig.PreCommitControl, control.ID, control.Key) {
+		uses = append(uses, "precommit")
+	}
+	if hasAddress(addrConfig.CommitControl, control.ID, control.Key) {
+		uses = append(uses, "commit")
+	}
+	if hasAddress(addrConfig.TerminateControl, control.ID, control.Key) {
+		uses = append(uses, "terminate")
+	}
+	return strings.Join(uses, ",")
+}
+
+// generateControlAddresses 输出 control 地址及其余额
+// generateControlAddresses prints every control address with its balance, and flags the WindowPoSt
+// capable addresses whose balance is below WPOST_MIN_BALANCE
+func generateControlAddresses(ctx context.Context, minerId address.Address, minerHost string, controls []controlAddress, worker, workerKey address.Address) error {
+	addrConfig, err := storageMiner.ActorAddressConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("ActorAddressConfig: %w", err)
+	}
+
+	fmt.Println("# HELP lotus_miner_control_address control addresses of the miner with their index and uses")
+	fmt.Println("# TYPE lotus_miner_control_address gauge")
+	fmt.Println("# HELP lotus_miner_control_balance balance of the control addresses")
+	fmt.Println("# TYPE lotus_miner_control_balance gauge")
+	fmt.Println("# HELP lotus_miner_wpost_address_low_balance set to 1 when an address able to send WindowPoSt has less than the minimum balance")
+	fmt.Println("# TYPE lotus_miner_wpost_address_low_balance gauge")
+
+	printLowBalance := func(role string, addr address.Address, balance types.BigInt) {
+		low := 0
+		if toFIL(balance) < Config.WPoStMinBalance {
+			low = 1
+		}
+		fmt.Print("lotus_miner_wpost_address_low_balance { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", role=", `"`, role, `"`, ", address=", `"`, addr, `"`, " } ", low, "\n")
+	}
+
+	for _, control := range controls {
+		balance, err := fullNode.WalletBalance(ctx, control.Key)
+		if err != nil {
+			return fmt.Errorf("WalletBalance %s: %w", control.Key, err)
+		}
+		fmt.Print("lotus_miner_control_address { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", index=", `"`, control.Index, `"`, ", role=", `"`, control.Role, `"`, ", address=", `"`, control.ID, `"`, ", key_address=", `"`, control.Key, `"`, ", uses=", `"`, controlUses(addrConfig, control), `"`, " } 1", "\n")
+		fmt.Print("lotus_miner_control_balance { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", index=", `"`, control.Index, `"`, ", role=", `"`, control.Role, `"`, ", address=", `"`, control.ID, `"`, " } ", toFIL(balance), "\n")
+		printLowBalance(control.Role, control.ID, balance)
+	}
+
+	// worker 在没有 control 地址可用时发送 WindowPoSt
+	// the worker sends WindowPoSt when no control address can
+	if !addrConfig.DisableWorkerFallback {
+		balance, err := fullNode.WalletBalance(ctx, workerKey)
+		if err != nil {
+			return fmt.Errorf("WalletBalance %s: %w", workerKey, err)
+		}
+		printLowBalance("worker", worker, balance)
+	}
+	return nil
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-bitfield"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// minerPartitions 是某个 tipset 上全部 deadline 和 partition 的状态, 每次运行只读取一次
+// minerPartitions holds the deadlines and partitions of the miner at one tipset, it is read once per
+// run and shared by the collectors that work on the head
+type minerPartitions struct {
+	Deadlines []api.Deadline
+	// Partitions holds the partitions of every deadline, by deadline index
+	Partitions [][]api.Partition
+}
+
+// loadMinerPartitions reads every deadline and partition of the miner at the tipset
+func loadMinerPartitions(ctx context.Context, minerId address.Address, tsk types.TipSetKey) (*minerPartitions, error) {
+	deadlines, err := fullNode.StateMinerDeadlines(ctx, minerId, tsk)
+	if err != nil {
+		return nil, fmt.Errorf("StateMinerDeadlines: %w", err)
+	}
+	parts := &minerPartitions{Deadlines: deadlines}
+	for dlIdx := range deadlines {
+		partitions, err := fullNode.StateMinerPartitions(ctx, minerId, uint64(dlIdx), tsk)
+		if err != nil {
+			return nil, fmt.Errorf("StateMinerPartitions %d: %w", dlIdx, err)
+		}
+		parts.Partitions = append(parts.Partitions, partitions)
+	}
+	return parts, nil
+}
+
+// partitionCounts 是一个 partition 或一个 deadline 内各类扇区的数量
+// partitionCounts holds the number of sectors of each kind in a partition, or summed over a deadline
+type partitionCounts struct {
+	All        uint64
+	Live       uint64
+	Active     uint64
+	Faulty     uint64
+	Recovering uint64
+}
+
+// countPartition returns the sector counts of a partition
+func countPartition(partition api.Partition) (partitionCounts, error) {
+	var counts partitionCounts
+	for _, field := range []struct {
+		count *uint64
+		set   bitfield.BitField
+	}{
+		{&counts.All, partition.AllSectors},
+		{&counts.Live, partition.LiveSectors},
+		{&counts.Active, partition.ActiveSectors},
+		{&counts.Faulty, partition.FaultySectors},
+		{&counts.Recovering, partition.RecoveringSectors},
+	} {
+		n, err := field.set.Count()
+		if err != nil {
+			return counts, err
+		}
+		*field.count = n
+	}
+	return counts, nil
+}
+
+func (c *partitionCounts) add(o partitionCounts) {
+	c.All += o.All
+	c.Live += o.Live
+	c.Active += o.Active
+	c.Faulty += o.Faulty
+	c.Recovering += o.Recovering
+}
+
+// generateDeadlines 输出每个 deadline 的信息, DEADLINE_PARTITIONS 打开时也输出每个 partition
+// generateDeadlines prints the sector counts and the proven partitions of every deadline, starting with
+// the current one, and of every partition when DEADLINE_PARTITIONS is set
+func generateDeadlines(ctx context.Context, minerId address.Address, minerHost string, tsk types.TipSetKey, parts *minerPartitions) error {
+	provenPartitions := parts.Deadlines
+	deadlines, err := fullNode.StateMinerProvingDeadline(ctx, minerId, tsk)
+	if err != nil {
+		return fmt.Errorf("StateMinerProvingDeadline: %w", err)
+	}
+	dlEpoch := deadlines.CurrentEpoch
+	dlIndex := deadlines.Index
+	dlOpen := deadlines.Open
+	dlNumbers := deadlines.WPoStPeriodDeadlines
+	dlWindow := deadlines.WPoStChallengeWindow
+	fmt.Println("# HELP lotus_miner_deadline_info deadlines and WPoSt informations")
+	fmt.Println("# TYPE lotus_miner_deadline_info gauge")
+	fmt.Print("lotus_miner_deadline_info { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", current_idx=", `"`, dlIndex, `"`, ", current_epoch=", `"`, dlEpoch, `"`, ",current_open_epoch=", `"`, dlOpen, `"`, ", wpost_period_deadlines=", `"`, dlNumbers, `"`, ", wpost_challenge_window=", `"`, dlWindow, `" } 1`, "\n")
+	fmt.Println("# HELP lotus_miner_deadline_active_start remaining time before deadline start")
+	fmt.Println("# TYPE lotus_miner_deadline_active_start gauge")
+	fmt.Println("# HELP lotus_miner_deadline_active_sectors_all number of sectors in the deadline")
+	fmt.Println("# TYPE lotus_miner_deadline_active_sectors_all gauge")
+	fmt.Println("# HELP lotus_miner_deadline_active_sectors_recovering number of sectors in recovering state")
+	fmt.Println("# TYPE lotus_miner_deadline_active_sectors_recovering gauge")
+	fmt.Println("# HELP lotus_miner_deadline_active_sectors_faulty number of faulty sectors")
+	fmt.Println("# TYPE lotus_miner_deadline_active_sectors_faulty gauge")
+	fmt.Println("# HELP lotus_miner_deadline_active_sectors_live number of live sectors")
+	fmt.Println("# TYPE lotus_miner_deadline_active_sectors_live gauge")
+	fmt.Println("# HELP lotus_miner_deadline_active_sectors_active number of active sectors")
+	fmt.Println("# TYPE lotus_miner_deadline_active_sectors_active gauge")
+	fmt.Println("# HELP lotus_miner_deadline_active_partitions number of partitions in the deadline")
+	fmt.Println("# TYPE lotus_miner_deadline_active_partitions gauge")
+	fmt.Println("# HELP lotus_miner_deadline_active_partitions_proven number of partitions already proven for the deadline")
+	fmt.Println("# TYPE lotus_miner_deadline_active_partitions_proven gauge")
+	if Config.DeadlinePartitions {
+		fmt.Println("# HELP lotus_miner_deadline_partition_sectors number of sectors of the partition by kind (all, live, active, faulty, recovering)")
+		fmt.Println("# TYPE lotus_miner_deadline_partition_sectors gauge")
+		fmt.Println("# HELP lotus_miner_deadline_partition_proven 1 when the partition is already proven for the current proving period")
+		fmt.Println("# TYPE lotus_miner_deadline_partition_proven gauge")
+	}
+	for i := uint64(0); i < dlNumbers; i++ {
+		idx := (dlIndex + i) % dlNumbers
+		opened := dlOpen + dlWindow*abi.ChainEpoch(i)
+		if idx >= uint64(len(parts.Partitions)) || parts.Partitions[idx] == nil {
+			continue
+		}
+		partitions := parts.Partitions[idx]
+		proven, err := provenPartitions[idx].PostSubmissions.Count()
+		if err != nil {
+			return err
+		}
+
+		var total partitionCounts
+		for partIdx, partition := range partitions {
+			counts, err := countPartition(partition)
+			if err != nil {
+				return err
+			}
+			total.add(counts)
+			if !Config.DeadlinePartitions {
+				continue
+			}
+			isProven, err := provenPartitions[idx].PostSubmissions.IsSet(uint64(partIdx))
+			if err != nil {
+				return err
+			}
+			provenValue := 0
+			if isProven {
+				provenValue = 1
+			}
+			fmt.Print("lotus_miner_deadline_partition_proven { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", deadline=", `"`, idx, `"`, ", partition=", `"`, partIdx, `"`, " } ", provenValue, "\n")
+			for _, kind := range []struct {
+				name  string
+				count uint64
+			}{
+				{"all", counts.All},
+				{"live", counts.Live},
+				{"active", counts.Active},
+				{"faulty", counts.Faulty},
+				{"recovering", counts.Recovering},
+			} {
+				fmt.Print("lotus_miner_deadline_partition_sectors { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", deadline=", `"`, idx, `"`, ", partition=", `"`, partIdx, `"`, ", kind=", `"`, kind.name, `"`, " } ", kind.count, "\n")
+			}
+		}
+		fmt.Print("lotus_miner_deadline_active_start { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", index=", `"`, idx, `"`, " } ", epochsToSeconds(opened-dlEpoch), "\n")
+		fmt.Print("lotus_miner_deadline_active_partitions_proven { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", index=", `"`, idx, `"`, " } ", proven, "\n")
+		fmt.Print("lotus_miner_deadline_active_partitions { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", index=", `"`, idx, `"`, " } ", len(partitions), "\n")
+		fmt.Print("lotus_miner_deadline_active_sectors_all { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", index=", `"`, idx, `"`, " } ", total.All, "\n")
+		fmt.Print("lotus_miner_deadline_active_sectors_recovering { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", index=", `"`, idx, `"`, " } ", total.Recovering, "\n")
+		fmt.Print("lotus_miner_deadline_active_sectors_faulty { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", index=", `"`, idx, `"`, " } ", total.Faulty, "\n")
+		fmt.Print("lotus_miner_deadline_active_sectors_active { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", index=", `"`, idx, `"`, " } ", total.Active, "\n")
+		fmt.Print("lotus_miner_deadline_active_sectors_live { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", index=", `"`, idx, `"`, " } ", total.Live, "\n")
+	}
+	return nil
+}
+
+// scheduledDeadline 是证明周期中的一个 deadline
+// scheduledDeadline is one deadline of the proving period schedule
+type scheduledDeadline struct {
+	Index      uint64
+	Open       abi.ChainEpoch
+	Close      abi.ChainEpoch
+	OpenTime   time.Time
+	CloseTime  time.Time
+	Partitions int
+	Sectors    uint64
+	Faulty     uint64
+}
+
+// deadlineSchedule 返回从当前 deadline 开始的一个完整证明周期, 时间使用 loc 时区
+// deadlineSchedule returns the deadlines of the next full proving period starting with the current
+// one, with their wall clock times in loc
+func deadlineSchedule(ctx context.Context, minerId address.Address, loc *time.Location) ([]scheduledDeadline, error) {
+	head, err := fullNode.ChainHead(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ChainHead: %w", err)
+	}
+	if err := loadBlockDelay(ctx, head); err != nil {
+		return nil, err
+	}
+	di, err := fullNode.StateMinerProvingDeadline(ctx, minerId, head.Key())
+	if err != nil {
+		return nil, fmt.Errorf("StateMinerProvingDeadline: %w", err)
+	}
+	var schedule []scheduledDeadline
+	for i := uint64(0); i < di.WPoStPeriodDeadlines; i++ {
+		idx := (di.Index + i) % di.WPoStPeriodDeadlines
+		partitions, err := fullNode.StateMinerPartitions(ctx, minerId, idx, head.Key())
+		if err != nil {
+			return nil, fmt.Errorf("StateMinerPartitions %d: %w", idx, err)
+		}
+		var total partitionCounts
+		for _, partition := range partitions {
+			counts, err := countPartition(partition)
+			if err != nil {
+				return nil, err
+			}
+			total.add(counts)
+		}
+		opened := di.Open + di.WPoStChallengeWindow*abi.ChainEpoch(i)
+		closed := opened + di.WPoStChallengeWindow
+		schedule = append(schedule, scheduledDeadline{
+			Index:      idx,
+			Open:       opened,
+			Close:      closed,
+			OpenTime:   epochTime(head, opened).In(loc),
+			CloseTime:  epochTime(head, closed).In(loc),
+			Partitions: len(partitions),
+			Sectors:    total.Live,
+			Faulty:     total.Faulty,
+		})
+	}
+	return schedule, nil
+}
+
+// deadlinesHandler returns the deadline schedule, the tz parameter overrides DEADLINE_TIMEZONE
+func deadlinesHandler(w http.ResponseWriter, r *http.Request) {
+	tz := r.URL.Query().Get("tz")
+	if tz == "" {
+		tz = Config.DeadlineTimezone
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	minerId, err := storageMiner.ActorAddress(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	schedule, err := deadlineSchedule(ctx, minerId, loc)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, schedule)
+}
+
+// deadlinesCmd 打印下一个证明周期的 deadline 时间表
+// deadlinesCmd prints the deadline schedule of the next proving period
+func deadlinesCmd(args []string) error {
+	fs := flag.NewFlagSet("deadlines", flag.ContinueOnError)
+	tz := fs.String("tz", Config.DeadlineTimezone, "time zone of the wall clock times, e.g. UTC or Asia/Shanghai")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	loc, err := time.LoadLocation(*tz)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	minerId, err := storageMiner.ActorAddress(ctx)
+	if err != nil {
+		return err
+	}
+	schedule, err := deadlineSchedule(ctx, minerId, loc)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "DEADLINE\tOPEN EPOCH\tCLOSE EPOCH\tOPENS\tCLOSES\tPARTITIONS\tSECTORS\tFAULTY")
+	for _, d := range schedule {
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%s\t%s\t%d\t%d\t%d\n", d.Index, d.Open, d.Close,
+			d.OpenTime.Format("2006-01-02 15:04:05 MST"), d.CloseTime.Format("15:04:05"), d.Partitions, d.Sectors, d.Faulty)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// dealSealingDuration 返回 DEAL_SEALING_DURATION, 配置错误时返回 0
+// dealSealingDuration returns DEAL_SEALING_DURATION, or zero when it can't be parsed
+func dealSealingDuration() time.Duration {
+	d, err := time.ParseDuration(Config.DealSealingDuration)
+	if err != nil {
+		fmt.Println("DEAL_SEALING_DURATION error", err)
+		return 0
+	}
+	return d
+}
+
+// generateDeals 输出封装中扇区的交易信息, 以及交易开始前可能来不及完成封装的扇区
+// generateDeals prints the deals of the sectors that are not proven yet. A sector is at risk when its
+// first deal starts before the sector can be sealed, the sealing end is estimated as the sector
+// creation time plus DEAL_SEALING_DURATION.
+func generateDeals(ctx context.Context, minerId address.Address, minerHost string, sectors []api.SectorInfo, head *types.TipSet, now int64) {
+	sealing := dealSealingDuration()
+	risk := 0
+
+	fmt.Println("# HELP lotus_miner_sector_sealing_deals_info deals of the sectors that are not proven yet")
+	fmt.Println("# TYPE lotus_miner_sector_sealing_deals_info gauge")
+	fmt.Println("# HELP lotus_miner_sector_sealing_deals_size_bytes padded piece size of the deal")
+	fmt.Println("# TYPE lotus_miner_sector_sealing_deals_size_bytes gauge")
+	fmt.Println("# HELP lotus_miner_sector_sealing_deals_price_per_epoch storage price per epoch of the deal in FIL")
+	fmt.Println("# TYPE lotus_miner_sector_sealing_deals_price_per_epoch gauge")
+	fmt.Println("# HELP lotus_miner_sector_sealing_deals_provider_collateral provider collateral of the deal in FIL")
+	fmt.Println("# TYPE lotus_miner_sector_sealing_deals_provider_collateral gauge")
+	fmt.Println("# HELP lotus_miner_sector_sealing_deals_client_collateral client collateral of the deal in FIL")
+	fmt.Println("# TYPE lotus_miner_sector_sealing_deals_client_collateral gauge")
+	fmt.Println("# HELP lotus_miner_sector_sealing_deals_start_epoch start epoch of the deal")
+	fmt.Println("# TYPE lotus_miner_sector_sealing_deals_start_epoch gauge")
+	fmt.Println("# HELP lotus_miner_sector_sealing_deals_end_epoch end epoch of the deal")
+	fmt.Println("# TYPE lotus_miner_sector_sealing_deals_end_epoch gauge")
+	fmt.Println("# HELP lotus_miner_sector_deal_start_margin_seconds time in seconds between the estimated end of sealing and the start of the first deal of the sector")
+	fmt.Println("# TYPE lotus_miner_sector_deal_start_margin_seconds gauge")
+	for _, detail := range sectors {
+		if provenStates[detail.State] {
+			continue
+		}
+		sector := detail.SectorID
+		firstStart := abi.ChainEpoch(-1)
+		for _, deal := range detail.Deals {
+			if deal == 0 {
+				continue
+			}
+			dealInfo, err := fullNode.StateMarketStorageDeal(ctx, deal, head.Key())
+			if err != nil {
+				fmt.Println("StateMarketStorageDeal error", deal, err)
+				continue
+			}
+			proposal := dealInfo.Proposal
+			fmt.Print("lotus_miner_sector_sealing_deals_info { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", sector_id=", `"`, sector, `"`, ", deal_id=", `"`, deal, `"`, ", deal_is_verified=", `"`, proposal.VerifiedDeal, `"`, ", deal_slash_epoch=", `"`, dealInfo.State.SlashEpoch, `"`, " } 1\n")
+			fmt.Print("lotus_miner_sector_sealing_deals_size_bytes { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", sector_id=", `"`, sector, `"`, ", deal_id=", `"`, deal, `"`, " } ", uint64(proposal.PieceSize), "\n")
+			fmt.Print("lotus_miner_sector_sealing_deals_price_per_epoch { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", sector_id=", `"`, sector, `"`, ", deal_id=", `"`, deal, `"`, " } ", toFIL(proposal.StoragePricePerEpoch), "\n")
+			fmt.Print("lotus_miner_sector_sealing_deals_provider_collateral { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", sector_id=", `"`, sector, `"`, ", deal_id=", `"`, deal, `"`, " } ", toFIL(proposal.ProviderCollateral), "\n")
+			fmt.Print("lotus_miner_sector_sealing_deals_client_collateral { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", sector_id=", `"`, sector, `"`, ", deal_id=", `"`, deal, `"`, " } ", toFIL(proposal.ClientCollateral), "\n")
+			fmt.Print("lotus_miner_sector_sealing_deals_start_epoch { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", sector_id=", `"`, sector, `"`, ", deal_id=", `"`, deal, `"`, " } ", proposal.StartEpoch, "\n")
+			fmt.Print("lotus_miner_sector_sealing_deals_end_epoch { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", sector_id=", `"`, sector, `"`, ", deal_id=", `"`, deal, `"`, " } ", proposal.EndEpoch, "\n")
+			if firstStart < 0 || proposal.StartEpoch < firstStart {
+				firstStart = proposal.StartEpoch
+			}
+		}
+		if firstStart < 0 || len(detail.Log) == 0 {
+			continue
+		}
+		sealedAt := int64(detail.Log[0].Timestamp) + int64(sealing.Seconds())
+		if sealedAt < now {
+			sealedAt = now
+		}
+		margin := epochTime(head, firstStart).Unix() - sealedAt
+		if margin < 0 {
+			risk++
+		}
+		fmt.Print("lotus_miner_sector_deal_start_margin_seconds { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", sector_id=", `"`, sector, `"`, ", state=", `"`, detail.State, `"`, " } ", margin, "\n")
+	}
+	fmt.Println("# HELP lotus_miner_sector_deal_start_risk number of sectors whose first deal starts before the sector can be sealed")
+	fmt.Println("# TYPE lotus_miner_sector_deal_start_risk gauge")
+	fmt.Print("lotus_miner_sector_deal_start_risk { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", sealing_duration=", `"`, sealing, `"`, " } ", risk, "\n")
+}
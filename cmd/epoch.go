@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// blockDelaySecs 是守护进程所在网络的出块间隔, 由 loadBlockDelay 读取
+// blockDelaySecs is the block time of the network the daemon follows, set by loadBlockDelay
+var blockDelaySecs int64
+
+// loadBlockDelay 根据创世块和链头的时间计算出块间隔, 只在第一次调用时读取
+// loadBlockDelay derives the block time from the timestamps of the genesis and the head, it is only
+// read on the first call
+func loadBlockDelay(ctx context.Context, head *types.TipSet) error {
+	if atomic.LoadInt64(&blockDelaySecs) > 0 {
+		return nil
+	}
+	if head.Height() == 0 {
+		return fmt.Errorf("block time unknown at the genesis")
+	}
+	genesis, err := fullNode.ChainGetGenesis(ctx)
+	if err != nil {
+		return fmt.Errorf("ChainGetGenesis: %w", err)
+	}
+	delay := int64(head.MinTimestamp()-genesis.MinTimestamp()) / int64(head.Height())
+	if delay <= 0 {
+		return fmt.Errorf("invalid block time %d", delay)
+	}
+	atomic.StoreInt64(&blockDelaySecs, delay)
+	return nil
+}
+
+// epochTime 根据链头时间和出块间隔计算某个高度的时间
+// epochTime returns the wall clock time of an epoch, computed from the head timestamp and the block time
+func epochTime(head *types.TipSet, epoch abi.ChainEpoch) time.Time {
+	return time.Unix(int64(head.MinTimestamp())+epochsToSeconds(epoch-head.Height()), 0)
+}
+
+// epochsToSeconds converts a number of epochs to seconds
+func epochsToSeconds(epochs abi.ChainEpoch) int64 {
+	return int64(epochs) * atomic.LoadInt64(&blockDelaySecs)
+}
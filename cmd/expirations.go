@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/lotus/chain/actors/builtin"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/types"
+	miner3 "github.com/filecoin-project/specs-actors/v3/actors/builtin/miner"
+)
+
+const (
+	epochsInWeek   = 7 * builtin.EpochsInDay
+	expirationWeek = 52
+)
+
+// sectorPartitions 返回每个扇区所在的 deadline 和 partition
+// sectorPartitions returns the deadline and partition of every sector of the miner
+func sectorPartitions(parts *minerPartitions) (map[abi.SectorNumber]miner.SectorLocation, error) {
+	locations := map[abi.SectorNumber]miner.SectorLocation{}
+	for dlIdx, partitions := range parts.Partitions {
+		for partIdx, partition := range partitions {
+			location := miner.SectorLocation{Deadline: uint64(dlIdx), Partition: uint64(partIdx)}
+			err := partition.AllSectors.ForEach(func(sector uint64) error {
+				locations[abi.SectorNumber(sector)] = location
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return locations, nil
+}
+
+// activeSectors 从链上扇区中取出 partition 中的有效扇区, 与 StateMinerActiveSectors 相同
+// activeSectors returns the sectors of onChain that are active in a partition, the same sectors as
+// StateMinerActiveSectors without fetching them again
+func activeSectors(onChain []*miner.SectorOnChainInfo, parts *minerPartitions) ([]*miner.SectorOnChainInfo, error) {
+	active := map[abi.SectorNumber]bool{}
+	for _, partitions := range parts.Partitions {
+		for _, partition := range partitions {
+			err := partition.ActiveSectors.ForEach(func(sector uint64) error {
+				active[abi.SectorNumber(sector)] = true
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	var sectors []*miner.SectorOnChainInfo
+	for _, sector := range onChain {
+		if active[sector.SectorNumber] {
+			sectors = append(sectors, sector)
+		}
+	}
+	return sectors, nil
+}
+
+// sectorQAPower returns the quality adjusted power of a sector
+func sectorQAPower(sectorSize abi.SectorSize, sector *miner.SectorOnChainInfo) abi.StoragePower {
+	return miner3.QAPowerForWeight(sectorSize, sector.Expiration-sector.Activation, sector.DealWeight, sector.VerifiedDealWeight)
+}
+
+// generateExpirations 输出未来一年每周到期的扇区数和有效算力
+// generateExpirations prints the number of sectors and the quality adjusted power expiring in each
+// week of the next year
+func generateExpirations(minerId address.Address, minerHost string, head *types.TipSet, sectorSize abi.SectorSize, onChain []*miner.SectorOnChainInfo, parts *minerPartitions) error {
+	active, err := activeSectors(onChain, parts)
+	if err != nil {
+		return err
+	}
+	var count [expirationWeek]int
+	var power [expirationWeek]abi.StoragePower
+	for i := range power {
+		power[i] = big.Zero()
+	}
+	for _, sector := range active {
+		week := (sector.Expiration - head.Height()) / epochsInWeek
+		if week < 0 || week >= expirationWeek {
+			continue
+		}
+		count[week]++
+		power[week] = big.Add(power[week], sectorQAPower(sectorSize, sector))
+	}
+
+	fmt.Println("# HELP lotus_miner_sectors_expiring number of active sectors expiring during the week, week 0 is the current one")
+	fmt.Println("# TYPE lotus_miner_sectors_expiring gauge")
+	fmt.Println("# HELP lotus_miner_qa_power_expiring_bytes quality adjusted power of the active sectors expiring during the week")
+	fmt.Println("# TYPE lotus_miner_qa_power_expiring_bytes gauge")
+	for week := 0; week < expirationWeek; week++ {
+		fmt.Print("lotus_miner_sectors_expiring { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", week=", `"`, week, `"`, " } ", count[week], "\n")
+		fmt.Print("lotus_miner_qa_power_expiring_bytes { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", week=", `"`, week, `"`, " } ", power[week], "\n")
+	}
+	return nil
+}
+
+// expirationsCmd 列出 N 天内到期的扇区, 按 deadline 和 partition 分组, 用于规划 ExtendSectorExpiration
+// expirationsCmd lists the sectors expiring within N days grouped by deadline and partition, to plan
+// ExtendSectorExpiration batches
+func expirationsCmd(args []string) error {
+	fs := flag.NewFlagSet("expirations", flag.ContinueOnError)
+	days := fs.Int("days", 30, "list the sectors expiring within this many days")
+	verbose := fs.Bool("sectors", false, "print the sector numbers of every group")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	minerId, err := storageMiner.ActorAddress(ctx)
+	if err != nil {
+		return err
+	}
+	head, err := fullNode.ChainHead(ctx)
+	if err != nil {
+		return err
+	}
+	if err := loadBlockDelay(ctx, head); err != nil {
+		return err
+	}
+	active, err := fullNode.StateMinerActiveSectors(ctx, minerId, head.Key())
+	if err != nil {
+		return fmt.Errorf("StateMinerActiveSectors: %w", err)
+	}
+	parts, err := loadMinerPartitions(ctx, minerId, head.Key())
+	if err != nil {
+		return err
+	}
+	locations, err := sectorPartitions(parts)
+	if err != nil {
+		return err
+	}
+
+	type group struct {
+		location miner.SectorLocation
+		sectors  []abi.SectorNumber
+		first    abi.ChainEpoch
+		last     abi.ChainEpoch
+	}
+	groups := map[miner.SectorLocation]*group{}
+	limit := head.Height() + abi.ChainEpoch(*days)*builtin.EpochsInDay
+	for _, sector := range active {
+		if sector.Expiration > limit {
+			continue
+		}
+		location := locations[sector.SectorNumber]
+		g, ok := groups[location]
+		if !ok {
+			g = &group{location: location, first: sector.Expiration, last: sector.Expiration}
+			groups[location] = g
+		}
+		g.sectors = append(g.sectors, sector.SectorNumber)
+		if sector.Expiration < g.first {
+			g.first = sector.Expiration
+		}
+		if sector.Expiration > g.last {
+			g.last = sector.Expiration
+		}
+	}
+	var sorted []*group
+	for _, g := range groups {
+		sorted = append(sorted, g)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].location.Deadline != sorted[j].location.Deadline {
+			return sorted[i].location.Deadline < sorted[j].location.Deadline
+		}
+		return sorted[i].location.Partition < sorted[j].location.Partition
+	})
+
+	tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "DEADLINE\tPARTITION\tSECTORS\tFIRST EXPIRATION\tLAST EXPIRATION")
+	for _, g := range sorted {
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%d (%s)\t%d (%s)\n", g.location.Deadline, g.location.Partition, len(g.sectors),
+			g.first, epochTime(head, g.first).Format("2006-01-02 15:04"), g.last, epochTime(head, g.last).Format("2006-01-02 15:04"))
+		if *verbose {
+			sort.Slice(g.sectors, func(i, j int) bool { return g.sectors[i] < g.sectors[j] })
+			fmt.Fprintf(tw, "\t\t%v\t\t\n", g.sectors)
+		}
+	}
+	return tw.Flush()
+}
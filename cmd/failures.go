@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/exitcode"
+)
+
+// MessageFailures 是某个角色调用某个方法以某个退出码失败的次数
+// MessageFailures counts the on chain messages of one role and method that failed with one exit code
+type MessageFailures struct {
+	Role     string
+	Method   string
+	ExitCode exitcode.ExitCode
+	Count    int64
+	// LastCid and LastHeight identify the last failed message
+	LastCid    string
+	LastHeight int64
+}
+
+// accountFailure 记录执行失败的消息
+// accountFailure counts the message when its receipt has a non zero exit code
+func accountFailure(state *State, msg *chainMessage) {
+	if msg.Receipt.ExitCode.IsSuccess() {
+		return
+	}
+	key := msg.Role + ";" + msg.Method + ";" + strconv.Itoa(int(msg.Receipt.ExitCode))
+	failures, ok := state.MessageFailures[key]
+	if !ok {
+		failures = &MessageFailures{
+			Role:     msg.Role,
+			Method:   msg.Method,
+			ExitCode: msg.Receipt.ExitCode,
+		}
+		state.MessageFailures[key] = failures
+	}
+	failures.Count++
+	if int64(msg.Height) >= failures.LastHeight {
+		failures.LastCid = msg.Cid.String()
+		failures.LastHeight = int64(msg.Height)
+	}
+}
+
+// sortedFailures returns the failure counters, the most recent failure first
+func sortedFailures(state *State) []*MessageFailures {
+	var failures []*MessageFailures
+	for _, f := range state.MessageFailures {
+		failures = append(failures, f)
+	}
+	sort.Slice(failures, func(i, j int) bool {
+		if failures[i].LastHeight != failures[j].LastHeight {
+			return failures[i].LastHeight > failures[j].LastHeight
+		}
+		return failures[i].LastCid < failures[j].LastCid
+	})
+	return failures
+}
+
+// generateFailures 输出失败消息计数
+// generateFailures prints the failed message counters
+func generateFailures(minerId address.Address, minerHost string, state *State) {
+	fmt.Println("# HELP lotus_miner_message_failures_total number of on chain messages sent by the miner addresses that failed, by method and exit code")
+	fmt.Println("# TYPE lotus_miner_message_failures_total counter")
+	for _, f := range sortedFailures(state) {
+		fmt.Print("lotus_miner_message_failures_total { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", role=", `"`, f.Role, `"`, ", method=", `"`, f.Method, `"`, ", exit_code=", `"`, int64(f.ExitCode), `"`, ", exit_code_name=", `"`, f.ExitCode, `"`, " } ", f.Count, "\n")
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-bitfield"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// FaultCounters 是扇区错误和恢复的累计数
+// FaultCounters are the cumulative fault and recovery counters
+type FaultCounters struct {
+	// Faulted is the number of sectors that became faulty
+	Faulted int64
+	// Declared is the number of sectors declared recovered by DeclareFaultsRecovered messages
+	Declared int64
+	// Proven is the number of faulty sectors that became healthy again, Failed the number of
+	// recovering sectors that went back to faulty without recovering
+	Proven int64
+	Failed int64
+	// Terminated is the number of faulty sectors terminated before they recovered
+	Terminated int64
+}
+
+// partitionFaults 返回链上的错误扇区, 正在恢复的扇区和全部有效扇区
+// partitionFaults returns the faulty, recovering and live sectors of the miner
+func partitionFaults(parts *minerPartitions) (map[uint64]bool, map[uint64]bool, map[uint64]bool, error) {
+	faulty := map[uint64]bool{}
+	recovering := map[uint64]bool{}
+	live := map[uint64]bool{}
+	for _, partitions := range parts.Partitions {
+		for _, partition := range partitions {
+			for _, set := range []struct {
+				sectors bitfield.BitField
+				into    map[uint64]bool
+			}{
+				{partition.FaultySectors, faulty},
+				{partition.RecoveringSectors, recovering},
+				{partition.LiveSectors, live},
+			} {
+				into := set.into
+				err := set.sectors.ForEach(func(sector uint64) error {
+					into[sector] = true
+					return nil
+				})
+				if err != nil {
+					return nil, nil, nil, err
+				}
+			}
+		}
+	}
+	return faulty, recovering, live, nil
+}
+
+// trackFaults 比较当前的错误扇区和上次运行时的记录, 第一次运行只记录不计数.
+// 错误扇区的终止高度取自链上的提前到期高度
+// trackFaults compares the faulty and recovering sectors with the ones of the previous run and updates
+// the counters. The first run only records the current faults, their age starts then. The termination
+// epoch of a new fault is read from the chain, it is the early expiration of the sector.
+func trackFaults(ctx context.Context, minerId address.Address, head *types.TipSet, parts *minerPartitions, state *State) error {
+	faulty, recovering, live, err := partitionFaults(parts)
+	if err != nil {
+		return err
+	}
+	first := state.FaultsHeight == 0
+	for sector := range faulty {
+		key := strconv.FormatUint(sector, 10)
+		if _, ok := state.FaultTermination[key]; !ok {
+			expiration, err := fullNode.StateSectorExpiration(ctx, minerId, abi.SectorNumber(sector), head.Key())
+			if err != nil {
+				return fmt.Errorf("StateSectorExpiration %d: %w", sector, err)
+			}
+			state.FaultTermination[key] = int64(expiration.Early)
+		}
+		if _, ok := state.FaultySince[key]; ok {
+			continue
+		}
+		state.FaultySince[key] = int64(head.Height())
+		if !first {
+			state.Faults.Faulted++
+		}
+	}
+	for key := range state.FaultySince {
+		sector, err := strconv.ParseUint(key, 10, 64)
+		if err != nil {
+			return err
+		}
+		if faulty[sector] {
+			if state.FaultRecovering[key] && !recovering[sector] {
+				state.Faults.Failed++
+			}
+			continue
+		}
+		if live[sector] {
+			state.Faults.Proven++
+		} else {
+			state.Faults.Terminated++
+		}
+		delete(state.FaultySince, key)
+		delete(state.FaultTermination, key)
+	}
+	state.FaultRecovering = map[string]bool{}
+	for sector := range recovering {
+		state.FaultRecovering[strconv.FormatUint(sector, 10)] = true
+	}
+	state.FaultsHeight = int64(head.Height())
+	return nil
+}
+
+// accountRecoveries 统计 DeclareFaultsRecovered 消息声明恢复的扇区数
+// accountRecoveries counts the sectors declared recovered by a successful DeclareFaultsRecovered message
+func accountRecoveries(minerId address.Address, state *State, msg *chainMessage) error {
+	if msg.Message.To != minerId || msg.Message.Method != miner.Methods.DeclareFaultsRecovered || msg.Receipt.ExitCode != 0 {
+		return nil
+	}
+	var params miner.DeclareFaultsRecoveredParams
+	if err := params.UnmarshalCBOR(bytes.NewReader(msg.Message.Params)); err != nil {
+		return fmt.Errorf("DeclareFaultsRecovered params %s: %w", msg.Cid, err)
+	}
+	for _, recovery := range params.Recoveries {
+		count, err := recovery.Sectors.Count()
+		if err != nil {
+			return err
+		}
+		state.Faults.Declared += int64(count)
+	}
+	return nil
+}
+
+// generateFaults 输出错误和恢复计数, 以及错误持续的时间
+// generateFaults prints the fault and recovery counters, how long the faults have been outstanding and
+// the time left before the first faulty sector is terminated by the network.
+func generateFaults(minerId address.Address, minerHost string, head *types.TipSet, state *State) {
+	fmt.Println("# HELP lotus_miner_sector_faults_total number of sectors that became faulty")
+	fmt.Println("# TYPE lotus_miner_sector_faults_total counter")
+	fmt.Print("lotus_miner_sector_faults_total { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, " } ", state.Faults.Faulted, "\n")
+	fmt.Println("# HELP lotus_miner_sector_recoveries_total number of faulty sectors by recovery step (declared, proven, failed, terminated)")
+	fmt.Println("# TYPE lotus_miner_sector_recoveries_total counter")
+	for _, step := range []struct {
+		name  string
+		count int64
+	}{
+		{"declared", state.Faults.Declared},
+		{"proven", state.Faults.Proven},
+		{"failed", state.Faults.Failed},
+		{"terminated", state.Faults.Terminated},
+	} {
+		fmt.Print("lotus_miner_sector_recoveries_total { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", step=", `"`, step.name, `"`, " } ", step.count, "\n")
+	}
+
+	var ages []int64
+	for _, since := range state.FaultySince {
+		ages = append(ages, epochsToSeconds(head.Height()-abi.ChainEpoch(since)))
+	}
+	sort.Slice(ages, func(i, j int) bool { return ages[i] > ages[j] })
+	var oldest int64
+	if len(ages) > 0 {
+		oldest = ages[0]
+	}
+	fmt.Println("# HELP lotus_miner_sector_faults_outstanding number of sectors currently faulty")
+	fmt.Println("# TYPE lotus_miner_sector_faults_outstanding gauge")
+	fmt.Print("lotus_miner_sector_faults_outstanding { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, " } ", len(ages), "\n")
+	fmt.Println("# HELP lotus_miner_sector_fault_oldest_seconds time in seconds the oldest outstanding fault has been faulty")
+	fmt.Println("# TYPE lotus_miner_sector_fault_oldest_seconds gauge")
+	fmt.Print("lotus_miner_sector_fault_oldest_seconds { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, " } ", oldest, "\n")
+	var terminations []int64
+	for _, early := range state.FaultTermination {
+		if early > 0 {
+			terminations = append(terminations, early)
+		}
+	}
+	if len(terminations) > 0 {
+		sort.Slice(terminations, func(i, j int) bool { return terminations[i] < terminations[j] })
+		fmt.Println("# HELP lotus_miner_sector_fault_termination_seconds time in seconds before the first faulty sector is terminated")
+		fmt.Println("# TYPE lotus_miner_sector_fault_termination_seconds gauge")
+		fmt.Print("lotus_miner_sector_fault_termination_seconds { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, " } ", epochsToSeconds(abi.ChainEpoch(terminations[0])-head.Height()), "\n")
+	}
+	if !Config.SectorDetails {
+		return
+	}
+	fmt.Println("# HELP lotus_miner_sector_fault_age_seconds time in seconds the sector has been faulty")
+	fmt.Println("# TYPE lotus_miner_sector_fault_age_seconds gauge")
+	for key, since := range state.FaultySince {
+		fmt.Print("lotus_miner_sector_fault_age_seconds { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", sector_id=", `"`, key, `"`, " } ", epochsToSeconds(head.Height()-abi.ChainEpoch(since)), "\n")
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// fakeExpirations answers StateSectorExpiration with an early expiration of 1000 + sector
+type fakeExpirations struct {
+	*fakeChain
+	calls int
+}
+
+func (f *fakeExpirations) StateSectorExpiration(ctx context.Context, maddr address.Address, sector abi.SectorNumber, tsk types.TipSetKey) (*miner.SectorExpiration, error) {
+	f.calls++
+	return &miner.SectorExpiration{OnTime: 5000, Early: 1000 + abi.ChainEpoch(sector)}, nil
+}
+
+func TestTrackFaults(t *testing.T) {
+	Config = defaultEnv()
+	f := &fakeExpirations{fakeChain: newFakeChain(400)}
+	fullNode = f
+	state := newState()
+	all := []uint64{1, 2, 3, 4, 5}
+
+	// 每一步是一次运行时链上的 partition 和运行后的计数
+	// every step is the partition at one run and the counters after it
+	tests := []struct {
+		name       string
+		height     abi.ChainEpoch
+		partition  api.Partition
+		counters   FaultCounters
+		faulty     int
+		recovering int
+		calls      int
+	}{
+		{"first run records the faults", 100, testPartition(all, []uint64{1, 2, 3}, nil), FaultCounters{}, 3, 0, 3},
+		{"new fault and recoveries declared", 200, testPartition(all, []uint64{1, 2, 3, 4}, []uint64{1, 2}), FaultCounters{Faulted: 1}, 4, 2, 4},
+		{"proven, failed and terminated", 300, testPartition([]uint64{1, 2, 4, 5}, []uint64{2, 4}, nil), FaultCounters{Faulted: 1, Proven: 1, Failed: 1, Terminated: 1}, 2, 0, 4},
+		{"all recovered", 400, testPartition([]uint64{1, 2, 4, 5}, nil, nil), FaultCounters{Faulted: 1, Proven: 3, Failed: 1, Terminated: 1}, 0, 0, 4},
+	}
+	for _, test := range tests {
+		parts := &minerPartitions{Partitions: [][]api.Partition{{test.partition}}}
+		err := trackFaults(context.Background(), testMiner, f.byHeight[test.height], parts, state)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := fmt.Sprint(state.Faults, len(state.FaultySince), len(state.FaultTermination), len(state.FaultRecovering), f.calls)
+		want := fmt.Sprint(test.counters, test.faulty, test.faulty, test.recovering, test.calls)
+		if got != want {
+			t.Errorf("%s: got %s, want %s", test.name, got, want)
+		}
+	}
+}
+
+func TestFaultTerminationFromChain(t *testing.T) {
+	Config = defaultEnv()
+	f := &fakeExpirations{fakeChain: newFakeChain(400)}
+	fullNode = f
+	state := newState()
+
+	// 第一次运行看到的错误, 终止高度也要取自链上, 而不是从第一次看到时开始算
+	// a fault already there on the first run still takes its termination epoch from the chain
+	parts := &minerPartitions{Partitions: [][]api.Partition{{testPartition([]uint64{1, 7}, []uint64{7}, nil)}}}
+	if err := trackFaults(context.Background(), testMiner, f.byHeight[400], parts, state); err != nil {
+		t.Fatal(err)
+	}
+	if got := state.FaultTermination["7"]; got != 1007 {
+		t.Errorf("termination epoch %d, want 1007", got)
+	}
+	if got := state.FaultySince["7"]; got != 400 {
+		t.Errorf("faulty since %d, want 400", got)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+)
+
+const secondsPerDay = 24 * 60 * 60
+
+// sealingRate 根据最近完成的扇区计算每天写入存储的字节数
+// sealingRate returns the bytes written to long term storage per day, from the number of sectors
+// finalized during the last FORECAST_WINDOW_DAYS days
+func sealingRate(finalized []int64, sectorSize abi.SectorSize, now int64) float64 {
+	window := Config.ForecastWindowDays * secondsPerDay
+	if window <= 0 {
+		return 0
+	}
+	count := 0
+	for _, timestamp := range finalized {
+		if now-timestamp <= int64(window) {
+			count++
+		}
+	}
+	return float64(count) * float64(sectorSize) / Config.ForecastWindowDays
+}
+
+// fillBytes 返回每个路径写满之前整个矿工写入的字节数. lotus 把新扇区放到 Available×Weight 最大的路径,
+// 所以路径按这个乘积持平的方式被写满
+// fillBytes returns, for every path, the bytes written to all the paths before that path is full. Lotus
+// stores a new sector on the path with the largest Available×Weight, so a path only takes sectors once
+// the paths ahead of it came down to its score, and the score of the paths being filled stays level.
+// A path is full when less than a sector is left, paths of weight 0 only take sectors once every other
+// path is full.
+func fillBytes(paths []*storagePath, sectorSize abi.SectorSize) map[*storagePath]float64 {
+	size := float64(sectorSize)
+	// remaining returns the bytes left on the path when the paths being filled have the score level
+	remaining := func(path *storagePath, level float64) float64 {
+		available := float64(path.Stat.Available)
+		if path.Info.Weight == 0 {
+			return available
+		}
+		return math.Min(available, math.Max(level/float64(path.Info.Weight), size))
+	}
+	var total float64
+	for _, path := range paths {
+		if available := float64(path.Stat.Available); available >= size {
+			total += available - size
+		}
+	}
+	fill := map[*storagePath]float64{}
+	for _, path := range paths {
+		switch {
+		case float64(path.Stat.Available) < size:
+			fill[path] = 0
+		case path.Info.Weight == 0:
+			fill[path] = total
+		default:
+			level := size * float64(path.Info.Weight)
+			var used float64
+			for _, other := range paths {
+				used += float64(other.Stat.Available) - remaining(other, level)
+			}
+			fill[path] = used
+		}
+	}
+	return fill
+}
+
+// generateStorageForecast 估算每个 CanStore 路径和整个矿工的存储还能用多少天.
+// 新扇区按 lotus 选择存储路径的方式分配, 见 fillBytes
+// generateStorageForecast estimates the number of days before every CanStore path, and the whole miner,
+// runs out of space. New sectors are placed on the paths the way lotus picks a path, see fillBytes.
+func generateStorageForecast(minerId address.Address, minerHost string, paths []*storagePath, finalized []int64, sectorSize abi.SectorSize, now int64) {
+	rate := sealingRate(finalized, sectorSize, now)
+
+	var stores []*storagePath
+	var totalAvailable int64
+	for _, path := range paths {
+		if path.Info.CanStore && path.StatErr == nil {
+			stores = append(stores, path)
+			totalAvailable += path.Stat.Available
+		}
+	}
+	fill := fillBytes(stores, sectorSize)
+
+	fmt.Println("# HELP lotus_miner_sealing_rate_bytes_per_day bytes of sectors finalized per day over the forecast window")
+	fmt.Println("# TYPE lotus_miner_sealing_rate_bytes_per_day gauge")
+	fmt.Println("# HELP lotus_miner_storage_days_remaining estimated number of days before the storage path is full")
+	fmt.Println("# TYPE lotus_miner_storage_days_remaining gauge")
+	fmt.Println("# HELP lotus_miner_storage_days_remaining_total estimated number of days before all the CanStore paths are full")
+	fmt.Println("# TYPE lotus_miner_storage_days_remaining_total gauge")
+	fmt.Print("lotus_miner_sealing_rate_bytes_per_day { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, " } ", rate, "\n")
+	for _, path := range stores {
+		days := math.Inf(1)
+		if fill[path] == 0 {
+			days = 0
+		} else if rate > 0 {
+			days = fill[path] / rate
+		}
+		fmt.Print("lotus_miner_storage_days_remaining { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", storage_id=", `"`, path.Info.ID, `"`, " } ", days, "\n")
+	}
+	days := math.Inf(1)
+	if rate > 0 {
+		days = float64(totalAvailable) / rate
+	}
+	fmt.Print("lotus_miner_storage_days_remaining_total { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, " } ", days, "\n")
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/extern/sector-storage/fsutil"
+	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
+)
+
+func TestFillBytes(t *testing.T) {
+	const sectorSize = abi.SectorSize(32 << 30)
+	// every path is {available sectors, weight}, fill is in sectors
+	tests := []struct {
+		name  string
+		paths [][2]int64
+		fill  []float64
+	}{
+		{"single path", [][2]int64{{10, 10}}, []float64{9}},
+		{"nearly full path waits for the others", [][2]int64{{100, 10}, {10, 10}}, []float64{108, 108}},
+		{"heavier path fills later", [][2]int64{{10, 2}, {10, 1}}, []float64{17, 18}},
+		{"full path", [][2]int64{{0, 10}, {10, 10}}, []float64{0, 9}},
+		{"weight 0 path is used last", [][2]int64{{10, 0}, {10, 10}}, []float64{18, 9}},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			var paths []*storagePath
+			for _, p := range test.paths {
+				paths = append(paths, &storagePath{
+					Info: stores.StorageInfo{Weight: uint64(p[1]), CanStore: true},
+					Stat: fsutil.FsStat{Available: p[0] * int64(sectorSize)},
+				})
+			}
+			fill := fillBytes(paths, sectorSize)
+			var got []float64
+			for _, path := range paths {
+				got = append(got, fill[path]/float64(sectorSize))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(test.fill) {
+				t.Errorf("got %v sectors, want %v", got, test.fill)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+const (
+	gasOveruseNum   = 11
+	gasOveruseDenom = 10
+)
+
+// GasSpent 是某个角色调用某个方法累计花费的 gas
+// GasSpent is the gas spent by one role on one actor method since farcaster started counting
+type GasSpent struct {
+	Role               string
+	Method             string
+	Messages           int64
+	BaseFeeBurn        types.BigInt
+	OverEstimationBurn types.BigInt
+	MinerTip           types.BigInt
+}
+
+// gasOutputs 根据收据计算消息的 gas 花费, 与 lotus 的 vm.ComputeGasOutputs 相同.
+// 不直接引用 chain/vm 是因为它依赖 filecoin-ffi (cgo)
+// gasOutputs computes the base fee burn, the over estimation burn and the miner tip of an executed
+// message from its receipt. It is the same computation as vm.ComputeGasOutputs in lotus, which can't
+// be imported here because chain/vm pulls filecoin-ffi in.
+func gasOutputs(message *types.Message, gasUsed int64, baseFee types.BigInt) (types.BigInt, types.BigInt, types.BigInt) {
+	baseFeeToPay := baseFee
+	if types.BigCmp(baseFee, message.GasFeeCap) > 0 {
+		baseFeeToPay = message.GasFeeCap
+	}
+	baseFeeBurn := types.BigMul(baseFeeToPay, types.NewInt(uint64(gasUsed)))
+
+	minerTip := message.GasPremium
+	if types.BigCmp(types.BigAdd(baseFeeToPay, minerTip), message.GasFeeCap) > 0 {
+		minerTip = types.BigSub(message.GasFeeCap, baseFeeToPay)
+	}
+	minerTip = types.BigMul(minerTip, types.NewInt(uint64(message.GasLimit)))
+
+	overEstimationBurn := types.NewInt(0)
+	if gasBurned := gasOverEstimationBurned(gasUsed, message.GasLimit); gasBurned != 0 {
+		overEstimationBurn = types.BigMul(baseFeeToPay, types.NewInt(uint64(gasBurned)))
+	}
+	return baseFeeBurn, overEstimationBurn, minerTip
+}
+
+// gasOverEstimationBurned returns the amount of gas burned because the gas limit was set too high
+func gasOverEstimationBurned(gasUsed, gasLimit int64) int64 {
+	if gasUsed == 0 {
+		return gasLimit
+	}
+	over := gasLimit - (gasOveruseNum*gasUsed)/gasOveruseDenom
+	if over < 0 {
+		return 0
+	}
+	if over > gasUsed {
+		over = gasUsed
+	}
+	// 病态情况下会溢出 int64
+	// overflows int64 in the pathological case
+	gasToBurn := big.NewInt(gasLimit - gasUsed)
+	gasToBurn.Mul(gasToBurn, big.NewInt(over))
+	gasToBurn.Div(gasToBurn, big.NewInt(gasUsed))
+	return gasToBurn.Int64()
+}
+
+// accountGas 把一条上链消息的 gas 花费加到计数器上
+// accountGas adds the gas spent by a message to the counters of its role and method
+func accountGas(state *State, msg *chainMessage) {
+	key := msg.Role + ";" + msg.Method
+	spent, ok := state.GasSpent[key]
+	if !ok {
+		spent = &GasSpent{
+			Role:               msg.Role,
+			Method:             msg.Method,
+			BaseFeeBurn:        types.NewInt(0),
+			OverEstimationBurn: types.NewInt(0),
+			MinerTip:           types.NewInt(0),
+		}
+		state.GasSpent[key] = spent
+	}
+	baseFeeBurn, overEstimationBurn, minerTip := gasOutputs(msg.Message, msg.Receipt.GasUsed, msg.BaseFee)
+	spent.Messages++
+	spent.BaseFeeBurn = types.BigAdd(spent.BaseFeeBurn, baseFeeBurn)
+	spent.OverEstimationBurn = types.BigAdd(spent.OverEstimationBurn, overEstimationBurn)
+	spent.MinerTip = types.BigAdd(spent.MinerTip, minerTip)
+}
+
+// generateGas 输出累计的 gas 花费
+// generateGas prints the cumulative gas spending counters
+func generateGas(minerId address.Address, minerHost string, state *State) {
+	fmt.Println("# HELP lotus_miner_chain_scan_height height of the last tipset scanned for messages sent by the miner addresses")
+	fmt.Println("# TYPE lotus_miner_chain_scan_height gauge")
+	fmt.Print("lotus_miner_chain_scan_height { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, " } ", state.ChainScanHeight, "\n")
+
+	fmt.Println("# HELP lotus_miner_gas_messages_total number of messages sent on chain by role and method")
+	fmt.Println("# TYPE lotus_miner_gas_messages_total counter")
+	fmt.Println("# HELP lotus_miner_gas_base_fee_burn_total FIL burned as base fee by role and method")
+	fmt.Println("# TYPE lotus_miner_gas_base_fee_burn_total counter")
+	fmt.Println("# HELP lotus_miner_gas_overestimation_burn_total FIL burned because of gas limit over estimation by role and method")
+	fmt.Println("# TYPE lotus_miner_gas_overestimation_burn_total counter")
+	fmt.Println("# HELP lotus_miner_gas_miner_tip_total FIL paid as miner tip by role and method")
+	fmt.Println("# TYPE lotus_miner_gas_miner_tip_total counter")
+
+	var keys []string
+	for key := range state.GasSpent {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		spent := state.GasSpent[key]
+		fmt.Print("lotus_miner_gas_messages_total { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", role=", `"`, spent.Role, `"`, ", method=", `"`, spent.Method, `"`, " } ", spent.Messages, "\n")
+		fmt.Print("lotus_miner_gas_base_fee_burn_total { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", role=", `"`, spent.Role, `"`, ", method=", `"`, spent.Method, `"`, " } ", toFIL(spent.BaseFeeBurn), "\n")
+		fmt.Print("lotus_miner_gas_overestimation_burn_total { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", role=", `"`, spent.Role, `"`, ", method=", `"`, spent.Method, `"`, " } ", toFIL(spent.OverEstimationBurn), "\n")
+		fmt.Print("lotus_miner_gas_miner_tip_total { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", role=", `"`, spent.Role, `"`, ", method=", `"`, spent.Method, `"`, " } ", toFIL(spent.MinerTip), "\n")
+	}
+}
+
+// toFIL 把 attoFIL 转成 FIL
+// toFIL converts an amount of attoFIL to FIL
+func toFIL(amount types.BigInt) float64 {
+	if amount.Int == nil {
+		return 0
+	}
+	fAmount := new(big.Float).SetInt(amount.Int)
+	afterAmount, _ := fAmount.Float64()
+	return afterAmount / 1000000000000000000.0
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// 数值来自 lotus chain/vm/burn_test.go
+// the expected values are the ones of lotus chain/vm/burn_test.go
+
+func TestGasOverEstimationBurned(t *testing.T) {
+	tests := []struct {
+		used  int64
+		limit int64
+		burn  int64
+	}{
+		{100, 200, 90},
+		{100, 150, 20},
+		{1000, 1300, 60},
+		{500, 700, 60},
+		{200, 200, 0},
+		{20000, 21000, 0},
+		{0, 2000, 2000},
+		{500, 651, 30},
+		{500, 5000, 4500},
+		{7499e6, 7500e6, 0},
+		{7500e6 / 2, 7500e6, 3375000000},
+		{1, 7500e6, 7499999999},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(fmt.Sprintf("%v", test), func(t *testing.T) {
+			if burn := gasOverEstimationBurned(test.used, test.limit); burn != test.burn {
+				t.Errorf("burned %d, want %d", burn, test.burn)
+			}
+		})
+	}
+}
+
+func TestGasOutputs(t *testing.T) {
+	baseFee := types.NewInt(10)
+	tests := []struct {
+		used    int64
+		limit   int64
+		feeCap  uint64
+		premium uint64
+
+		baseFeeBurn        uint64
+		overEstimationBurn uint64
+		minerTip           uint64
+	}{
+		{100, 110, 11, 1, 1000, 0, 110},
+		{100, 130, 11, 1, 1000, 60, 130},
+		{100, 110, 10, 1, 1000, 0, 0},
+		{100, 110, 6, 1, 600, 0, 0},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(fmt.Sprintf("%v", test), func(t *testing.T) {
+			message := &types.Message{
+				GasLimit:   test.limit,
+				GasFeeCap:  types.NewInt(test.feeCap),
+				GasPremium: types.NewInt(test.premium),
+			}
+			baseFeeBurn, overEstimationBurn, minerTip := gasOutputs(message, test.used, baseFee)
+			if baseFeeBurn.String() != fmt.Sprint(test.baseFeeBurn) {
+				t.Errorf("base fee burn %s, want %d", baseFeeBurn, test.baseFeeBurn)
+			}
+			if overEstimationBurn.String() != fmt.Sprint(test.overEstimationBurn) {
+				t.Errorf("over estimation burn %s, want %d", overEstimationBurn, test.overEstimationBurn)
+			}
+			if minerTip.String() != fmt.Sprint(test.minerTip) {
+				t.Errorf("miner tip %s, want %d", minerTip, test.minerTip)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/types"
+	miner3 "github.com/filecoin-project/specs-actors/v3/actors/builtin/miner"
+	"github.com/ipfs/go-cid"
+)
+
+// pendingOwner 返回等待确认的新 owner, StateMinerInfo 不返回这个字段, 需要从矿工状态读取
+// pendingOwner returns the proposed owner waiting for confirmation, if any. StateMinerInfo doesn't
+// return it so it is read from the miner actor state.
+func pendingOwner(ctx context.Context, minerId address.Address) (*address.Address, error) {
+	actorState, err := fullNode.StateReadState(ctx, minerId, types.EmptyTSK)
+	if err != nil {
+		return nil, fmt.Errorf("StateReadState %s: %w", minerId, err)
+	}
+	raw, err := json.Marshal(actorState.State)
+	if err != nil {
+		return nil, err
+	}
+	var st struct {
+		Info cid.Cid
+	}
+	if err := json.Unmarshal(raw, &st); err != nil {
+		return nil, fmt.Errorf("miner state %s: %w", minerId, err)
+	}
+	data, err := fullNode.ChainReadObj(ctx, st.Info)
+	if err != nil {
+		return nil, fmt.Errorf("ChainReadObj %s: %w", st.Info, err)
+	}
+	var info miner3.MinerInfo
+	if err := info.UnmarshalCBOR(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("miner info %s: %w", st.Info, err)
+	}
+	return info.PendingOwnerAddress, nil
+}
+
+// generateKeyChanges 输出待生效的 worker 变更和待确认的 owner 变更
+// generateKeyChanges prints the pending worker key change with the epoch it takes effect and the
+// owner change waiting for confirmation
+func generateKeyChanges(ctx context.Context, minerId address.Address, minerHost string, info miner.MinerInfo, head *types.TipSet) error {
+	fmt.Println("# HELP lotus_miner_worker_change_pending set to 1 when a worker key change is pending")
+	fmt.Println("# TYPE lotus_miner_worker_change_pending gauge")
+	fmt.Println("# HELP lotus_miner_worker_change_epoch epoch the pending worker key change takes effect, -1 when none is pending")
+	fmt.Println("# TYPE lotus_miner_worker_change_epoch gauge")
+	fmt.Println("# HELP lotus_miner_worker_change_countdown time in seconds before the pending worker key change takes effect")
+	fmt.Println("# TYPE lotus_miner_worker_change_countdown gauge")
+	fmt.Println("# HELP lotus_miner_owner_change_pending set to 1 when an owner change is waiting for the new owner confirmation")
+	fmt.Println("# TYPE lotus_miner_owner_change_pending gauge")
+
+	workerPending := 0
+	newWorker := ""
+	newWorkerAddr := ""
+	changeEpoch := abi.ChainEpoch(-1)
+	var countdown int64
+	if info.NewWorker != address.Undef && info.NewWorker != info.Worker {
+		workerPending = 1
+		newWorker = info.NewWorker.String()
+		key, err := accountKeyOrID(ctx, info.NewWorker, false)
+		if err != nil {
+			return err
+		}
+		newWorkerAddr = key.String()
+		changeEpoch = info.WorkerChangeEpoch
+		if changeEpoch > head.Height() {
+			countdown = epochsToSeconds(changeEpoch - head.Height())
+		}
+	}
+	fmt.Print("lotus_miner_worker_change_pending { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", worker=", `"`, info.Worker, `"`, ", new_worker=", `"`, newWorker, `"`, ", new_worker_addr=", `"`, newWorkerAddr, `"`, " } ", workerPending, "\n")
+	fmt.Print("lotus_miner_worker_change_epoch { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, " } ", changeEpoch, "\n")
+	fmt.Print("lotus_miner_worker_change_countdown { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, " } ", countdown, "\n")
+
+	newOwner, err := pendingOwner(ctx, minerId)
+	if err != nil {
+		return err
+	}
+	ownerPending := 0
+	newOwnerStr := ""
+	if newOwner != nil {
+		ownerPending = 1
+		newOwnerStr = newOwner.String()
+	}
+	fmt.Print("lotus_miner_owner_change_pending { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", owner=", `"`, info.Owner, `"`, ", new_owner=", `"`, newOwnerStr, `"`, " } ", ownerPending, "\n")
+	return nil
+}
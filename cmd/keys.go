@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/filecoin-project/go-address"
+)
+
+// generateKeyPresence 检查节点钱包中是否有 owner, worker 和 control 地址的私钥.
+// 多签 owner 没有私钥, 不检查
+// generateKeyPresence checks that the node wallet holds the keys of the owner, the worker and every
+// control address. A multisig owner has no key of its own and is skipped.
+func generateKeyPresence(ctx context.Context, minerId address.Address, minerHost string, owner address.Address, ownerIsMultisig bool, worker address.Address, controls []controlAddress) error {
+	fmt.Println("# HELP lotus_miner_key_present set to 1 when the daemon wallet holds the key of the address")
+	fmt.Println("# TYPE lotus_miner_key_present gauge")
+
+	type roleAddress struct {
+		role string
+		addr address.Address
+	}
+	var keys []roleAddress
+	if !ownerIsMultisig {
+		keys = append(keys, roleAddress{"owner", owner})
+	}
+	keys = append(keys, roleAddress{"worker", worker})
+	for _, control := range controls {
+		keys = append(keys, roleAddress{control.Role, control.Key})
+	}
+
+	for _, key := range keys {
+		has, err := fullNode.WalletHas(ctx, key.addr)
+		if err != nil {
+			return fmt.Errorf("WalletHas %s: %w", key.addr, err)
+		}
+		present := 0
+		if has {
+			present = 1
+		}
+		fmt.Print("lotus_miner_key_present { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", role=", `"`, key.role, `"`, ", address=", `"`, key.addr, `"`, " } ", present, "\n")
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// sectorLocation 是扇区文件所在的存储路径
+// sectorLocation tells which storage paths hold the files of a sector
+type sectorLocation struct {
+	Sector abi.SectorNumber
+	State  api.SectorState
+	// Files maps a file type (sealed, cache, unsealed) to the paths holding it
+	Files map[string][]stores.SectorStorageInfo
+	// NoSealed is set when no path holds a sealed copy
+	NoSealed bool
+	// ScratchOnly is set when every sealed copy is on a CanSeal only path
+	ScratchOnly bool
+}
+
+// findSectorLocation 查找扇区的全部文件位置
+// findSectorLocation looks the files of a sector up in the sector index
+func findSectorLocation(ctx context.Context, minerId address.Address, sectorSize abi.SectorSize, sector abi.SectorNumber) (*sectorLocation, error) {
+	actorId, err := address.IDFromAddress(minerId)
+	if err != nil {
+		return nil, err
+	}
+	status, err := storageMiner.SectorsStatus(ctx, sector, false)
+	if err != nil {
+		return nil, fmt.Errorf("SectorsStatus %d: %w", sector, err)
+	}
+	location := &sectorLocation{
+		Sector: sector,
+		State:  status.State,
+		Files:  map[string][]stores.SectorStorageInfo{},
+	}
+	sid := abi.SectorID{Miner: abi.ActorID(actorId), Number: sector}
+	for _, fileType := range storiface.PathTypes {
+		infos, err := storageMiner.StorageFindSector(ctx, sid, fileType, sectorSize, false)
+		if err != nil {
+			return nil, fmt.Errorf("StorageFindSector %d %s: %w", sector, fileType, err)
+		}
+		location.Files[fileType.String()] = infos
+	}
+
+	sealed := location.Files[storiface.FTSealed.String()]
+	location.NoSealed = len(sealed) == 0
+	location.ScratchOnly = len(sealed) > 0
+	for _, info := range sealed {
+		if info.CanStore || !info.CanSeal {
+			location.ScratchOnly = false
+		}
+	}
+	return location, nil
+}
+
+// findSectorLocations 查找处于指定状态的扇区的文件位置
+// findSectorLocations looks up the files of every sector in one of the states
+func findSectorLocations(ctx context.Context, minerId address.Address, sectorSize abi.SectorSize, states []string) ([]*sectorLocation, error) {
+	var sectorStates []api.SectorState
+	for _, state := range states {
+		sectorStates = append(sectorStates, api.SectorState(state))
+	}
+	sectors, err := storageMiner.SectorsListInStates(ctx, sectorStates)
+	if err != nil {
+		return nil, fmt.Errorf("SectorsListInStates: %w", err)
+	}
+	var locations []*sectorLocation
+	for _, sector := range sectors {
+		location, err := findSectorLocation(ctx, minerId, sectorSize, sector)
+		if err != nil {
+			return nil, err
+		}
+		locations = append(locations, location)
+	}
+	return locations, nil
+}
+
+// generateSectorLocations 输出扇区文件位置, 只在配置了 SECTOR_LOCATION_STATES 时启用
+// generateSectorLocations prints where the files of the sectors in SECTOR_LOCATION_STATES are, it is
+// disabled when no state is configured
+func generateSectorLocations(ctx context.Context, minerId address.Address, minerHost string, sectorSize abi.SectorSize) error {
+	if len(Config.SectorLocationStates) == 0 {
+		return nil
+	}
+	locations, err := findSectorLocations(ctx, minerId, sectorSize, Config.SectorLocationStates)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("# HELP lotus_miner_sector_location storage path holding a file of the sector")
+	fmt.Println("# TYPE lotus_miner_sector_location gauge")
+	fmt.Println("# HELP lotus_miner_sector_location_issue set for sectors without a sealed copy or with their sealed copy on scratch space only")
+	fmt.Println("# TYPE lotus_miner_sector_location_issue gauge")
+	for _, location := range locations {
+		for fileType, infos := range location.Files {
+			for _, info := range infos {
+				fmt.Print("lotus_miner_sector_location { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", sector_id=", `"`, location.Sector, `"`, ", state=", `"`, location.State, `"`, ", file_type=", `"`, fileType, `"`, ", storage_id=", `"`, info.ID, `"`, ", urls=", `"`, strings.Join(info.URLs, ","), `"`, " } 1", "\n")
+			}
+		}
+		if location.NoSealed {
+			fmt.Print("lotus_miner_sector_location_issue { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", sector_id=", `"`, location.Sector, `"`, ", state=", `"`, location.State, `"`, `, issue="no_sealed" } 1`, "\n")
+		}
+		if location.ScratchOnly {
+			fmt.Print("lotus_miner_sector_location_issue { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", sector_id=", `"`, location.Sector, `"`, ", state=", `"`, location.State, `"`, `, issue="scratch_only" } 1`, "\n")
+		}
+	}
+	return nil
+}
+
+// sectorLocationsHandler 按需查询扇区文件位置, 参数 sectors=1,2,3 或 states=Faulty,Proving
+// sectorLocationsHandler looks up sector files on demand, for the sectors listed in the "sectors"
+// parameter or the sectors in one of the "states"
+func sectorLocationsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	minerId, err := storageMiner.ActorAddress(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	sectorSize, err := storageMiner.ActorSectorSize(ctx, minerId)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	var locations []*sectorLocation
+	if sectors := r.URL.Query().Get("sectors"); sectors != "" {
+		for _, s := range strings.Split(sectors, ",") {
+			number, err := strconv.ParseUint(strings.TrimSpace(s), 10, 64)
+			if err != nil {
+				http.Error(w, "invalid sector number "+s, http.StatusBadRequest)
+				return
+			}
+			location, err := findSectorLocation(ctx, minerId, sectorSize, abi.SectorNumber(number))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			locations = append(locations, location)
+		}
+	} else if states := r.URL.Query().Get("states"); states != "" {
+		locations, err = findSectorLocations(ctx, minerId, sectorSize, strings.Split(states, ","))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+	} else {
+		http.Error(w, "either sectors or states is required", http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, locations)
+}
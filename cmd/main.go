@@ -3,11 +3,13 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-jsonrpc"
 	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/api/apistruct"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
 	"github.com/filecoin-project/lotus/chain/types"
 	ma "github.com/multiformats/go-multiaddr"
 	manet "github.com/multiformats/go-multiaddr/net"
@@ -16,7 +18,6 @@ import (
 	"math/big"
 	"net/http"
 	"os"
-	"strconv"
 	"strings"
 	"time"
 )
@@ -28,6 +29,10 @@ var (
 	DaemonToken = ""
 )
 
+// Config 是从配置文件读取的设置, 未配置的项使用默认值
+// Config holds the settings read from the configuration file, unset entries keep their default
+var Config = defaultEnv()
+
 var storageMiner api.StorageMiner
 var fullNode api.FullNode
 
@@ -45,8 +50,37 @@ func getCredentials(listenAddr string) (string, error) {
 }
 
 func main() {
+	// 子命令
+	// SUB COMMANDS
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1], os.Args[2:])
+		return
+	}
 	// 起始时间时间戳
 	StartTime := time.Now().Unix()
+	// 加载上次运行保存的状态
+	// LOAD STATE SAVED BY THE PREVIOUS RUN
+	// 读取失败时不保存, 避免覆盖原来的计数. 损坏的文件被移开, 下次运行从空状态开始
+	// the state is not saved when it could not be loaded, so that the counters on disk are not wiped.
+	// A corrupt file is moved aside, the next run starts with an empty state.
+	state, err := loadState(Config.StatePath)
+	if err != nil {
+		fmt.Println("loadState error", err)
+	}
+	stateLoaded := err == nil
+	if errors.Is(err, errStateCorrupt) {
+		if err := moveStateAside(Config.StatePath); err != nil {
+			fmt.Println("moveStateAside error", err)
+		}
+	}
+	defer func() {
+		if !stateLoaded {
+			return
+		}
+		if err := saveState(Config.StatePath, state); err != nil {
+			fmt.Println("saveState error", err)
+		}
+	}()
 	// 检索矿工ID
 	// RETRIEVE MINER ID
 	actorAddress, err := storageMiner.ActorAddress(context.Background())
@@ -67,6 +101,11 @@ func main() {
 		fmt.Println("ChainHead error", err)
 		return
 	}
+	err = loadBlockDelay(context.Background(), chainHead)
+	if err != nil {
+		fmt.Println("blockDelay error", err)
+		return
+	}
 
 	var emptyTipSetKey types.TipSetKey
 	fmt.Println("# HELP lotus_chain_height return current height")
@@ -123,7 +162,14 @@ func main() {
 		return
 	}
 	minerOwner := daemonStats.Owner
-	minerOwnerAddr, err := fullNode.StateAccountKey(context.Background(), minerOwner, emptyTipSetKey)
+	// owner 可以是多签, 此时没有 account key, 使用 ID 地址
+	// the owner can be a multisig, it has no account key and the ID address is used instead
+	ownerIsMultisig, err := isMultisig(context.Background(), minerOwner)
+	if err != nil {
+		fmt.Println("ownerIsMultisig error", err)
+		return
+	}
+	minerOwnerAddr, err := accountKeyOrID(context.Background(), minerOwner, ownerIsMultisig)
 	if err != nil {
 		fmt.Println("minerOwnerAddr error", err)
 		return
@@ -135,17 +181,27 @@ func main() {
 		fmt.Println("minerWorkerAddr error", err)
 		return
 	}
-	var minerControl0 address.Address
-	if daemonStats.ControlAddresses != nil {
-		minerControl0 = daemonStats.ControlAddresses[0]
-	} else {
-		minerControl0 = minerWorker
-	}
-	minerControl0Addr, err := fullNode.StateAccountKey(context.Background(), minerControl0, emptyTipSetKey)
+	minerControls, err := resolveControlAddresses(context.Background(), daemonStats.ControlAddresses)
 	if err != nil {
-		fmt.Println("minerControl0Addr error", err)
+		fmt.Println("minerControls error", err)
 		return
 	}
+	minerControl0 := minerWorker
+	minerControl0Addr := minerWorkerAddr
+	if len(minerControls) > 0 {
+		minerControl0 = minerControls[0].ID
+		minerControl0Addr = minerControls[0].Key
+	}
+	// 地址角色, owner 优先于 worker, worker 优先于 control
+	// address roles, owner takes precedence over worker and worker over control
+	addrRoles := map[address.Address]string{}
+	for _, control := range minerControls {
+		addrRoles[control.Key] = control.Role
+	}
+	addrRoles[minerWorkerAddr] = "worker"
+	if !ownerIsMultisig {
+		addrRoles[minerOwnerAddr] = "owner"
+	}
 	fmt.Println("# HELP lotus_miner_info lotus miner information like adress version etc")
 	fmt.Println("# TYPE lotus_miner_info gauge")
 	fmt.Println("# HELP lotus_miner_info_sector_size lotus miner sector size")
@@ -153,6 +209,36 @@ func main() {
 	fmt.Print("lotus_miner_info { miner_id = ", `"`, minerId, `"`, ", miner_host = ", `"`, minerHost, `"`, ", version=", `"`, minerVersion.Version, `"`, ", owner=", `"`, minerOwner, `"`, ", owner_addr=", `"`, minerOwnerAddr, `"`, ", worker=", `"`, minerWorker, `"`, ", worker_addr=", `"`, minerWorkerAddr, `"`, ", control0=", `"`, minerControl0, `"`, ", control0_addr=", `"`, minerControl0Addr, `"`, " } 1", "\n")
 	fmt.Print("lotus_miner_info_sector_size { miner_id = ", `"`, minerId, `"`, " } ", daemonStats.SectorSize, "\n")
 
+	// 生成多签信息
+	// GENERATE MULTISIG INFO
+	if ownerIsMultisig {
+		err = generateMultisig(context.Background(), minerId, minerHost, "owner", minerOwner)
+		if err != nil {
+			fmt.Println("multisig error", err)
+		}
+	}
+
+	// 检查签名私钥
+	// CHECK SIGNING KEYS
+	err = generateKeyPresence(context.Background(), minerId, minerHost, minerOwnerAddr, ownerIsMultisig, minerWorkerAddr, minerControls)
+	if err != nil {
+		fmt.Println("keyPresence error", err)
+	}
+
+	// 生成 owner/worker 变更信息
+	// GENERATE OWNER/WORKER KEY CHANGES
+	err = generateKeyChanges(context.Background(), minerId, minerHost, daemonStats, chainHead)
+	if err != nil {
+		fmt.Println("keyChanges error", err)
+	}
+
+	// 生成 CONTROL 地址信息
+	// GENERATE CONTROL ADDRESSES
+	err = generateControlAddresses(context.Background(), minerId, minerHost, minerControls, minerWorker, minerWorkerAddr)
+	if err != nil {
+		fmt.Println("controlAddresses error", err)
+	}
+
 	// 生成daemon信息
 	// GENERATE DAEMON INFO
 	daemonNetwork, err := fullNode.StateNetworkName(context.Background())
@@ -184,29 +270,21 @@ func main() {
 	fmt.Println("# HELP lotus_mpool_total return number of message pending in mpool")
 	fmt.Println("# TYPE lotus_mpool_total gauge")
 	fmt.Println("# HELP lotus_mpool_local_total return total number in mpool comming from local adresses")
-	fmt.Println("# TYPE lotus_power_local_total gauge")
+	fmt.Println("# TYPE lotus_mpool_local_total gauge")
 	fmt.Println("# HELP lotus_mpool_local_message local message details")
 	fmt.Println("# TYPE lotus_mpool_local_message gauge")
 	mPoolTotal := 0
 	mPoolLocalTotal := 0
+	localMessages := map[address.Address][]*types.SignedMessage{}
 	for _, message := range mPoolPending {
 		mPoolTotal += 1
 		frm := message.Message.From
 		for _, value := range walletList {
 			if value == frm {
 				mPoolLocalTotal += 1
-				var displayAddr string
-				if frm == minerOwnerAddr {
-					displayAddr = "owner"
-				} else if frm == minerWorkerAddr {
-					displayAddr = "worker"
-				} else if frm == minerControl0Addr {
-					displayAddr = "control0"
-				} else if frm != minerId {
-					// displayAddr = frm[0:5]
-					displayAddr = frm.String()[0:5] + "..." + frm.String()[len(frm.String()):]
-				}
-				fmt.Print("lotus_mpool_local_message { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", from=", `"`, displayAddr, `"`, ", to=", `"`, message.Message.To, `"`, ", nonce=", `"`, message.Message.Nonce, `"`, ", value=", `"`, message.Message.Value, `"`, ", gaslimit=", `"`, message.Message.GasLimit, `"`, ", gasfeecap=", `"`, message.Message.GasFeeCap, `"`, ", gaspremium=", `"`, message.Message.GasPremium, `"`, ", method=", `"`, message.Message.Method, " } 1", "\n")
+				localMessages[frm] = append(localMessages[frm], message)
+				displayAddr := addrRole(addrRoles, frm)
+				fmt.Print("lotus_mpool_local_message { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", from=", `"`, displayAddr, `"`, ", to=", `"`, message.Message.To, `"`, ", nonce=", `"`, message.Message.Nonce, `"`, ", value=", `"`, message.Message.Value, `"`, ", gaslimit=", `"`, message.Message.GasLimit, `"`, ", gasfeecap=", `"`, message.Message.GasFeeCap, `"`, ", gaspremium=", `"`, message.Message.GasPremium, `"`, ", method=", `"`, message.Message.Method, `"`, " } 1", "\n")
 			}
 		}
 	}
@@ -214,6 +292,42 @@ func main() {
 	fmt.Print("lotus_mpool_total { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, " } ", mPoolTotal, "\n")
 	fmt.Print("lotus_mpool_local_total { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, " } ", mPoolLocalTotal, "\n")
 
+	// 检测卡住的本地消息
+	// DETECT STUCK LOCAL MESSAGES
+	baseFee := chainHead.Blocks()[0].ParentBaseFee
+	err = generateMpoolStuck(context.Background(), minerId, minerHost, localMessages, addrRoles, baseFee, state, StartTime)
+	if err != nil {
+		fmt.Println("mpoolStuck error", err)
+	}
+
+	// 扫描矿工地址发送的上链消息
+	// SCAN ON CHAIN MESSAGES SENT FROM THE MINER ADDRESSES
+	chainRoles := map[address.Address]string{}
+	for addr, role := range addrRoles {
+		chainRoles[addr] = role
+	}
+	for _, control := range minerControls {
+		chainRoles[control.ID] = control.Role
+	}
+	chainRoles[minerWorker] = "worker"
+	chainRoles[minerOwner] = "owner"
+	err = scanChainMessages(context.Background(), minerId, chainHead, chainRoles, state, func(msg *chainMessage) {
+		accountGas(state, msg)
+		accountFailure(state, msg)
+		if err := accountWPoSt(context.Background(), minerId, chainHead, state, msg); err != nil {
+			fmt.Println("accountWPoSt error", err)
+		}
+		if err := accountRecoveries(minerId, state, msg); err != nil {
+			fmt.Println("accountRecoveries error", err)
+		}
+	})
+	if err != nil {
+		fmt.Println("scanChainMessages error", err)
+	}
+	generateGas(minerId, minerHost, state)
+	generateFailures(minerId, minerHost, state)
+	generateWPoStSubmissions(minerId, minerHost, state)
+
 	// 生成 WORKER 信息
 	// GENERATE WORKER INFOS
 	workerStats, err := storageMiner.WorkerStats(context.Background())
@@ -311,203 +425,134 @@ func main() {
 		}
 	}
 
+	// 生成存储路径信息
+	// GENERATE STORAGE PATHS
+	storagePaths, err := listStoragePaths(context.Background())
+	if err != nil {
+		fmt.Println("storagePaths error", err)
+	}
+	generateStorage(minerId, minerHost, storagePaths)
+	err = generateSectorLocations(context.Background(), minerId, minerHost, daemonStats.SectorSize)
+	if err != nil {
+		fmt.Println("sectorLocations error", err)
+	}
+
 	// 生成  SECTORS
 	// GENERATE SECTORS
-	fmt.Println("# HELP lotus_miner_sector_state sector state")
-	fmt.Println("# TYPE lotus_miner_sector_state gauge")
-	fmt.Println("# HELP lotus_miner_sector_event contains important event of the sector life")
-	fmt.Println("# TYPE lotus_miner_sector_event gauge")
-	fmt.Println("# HELP lotus_miner_sector_sealing_deals_info contains information related to deals that are not in Proving and Removed state.")
-	fmt.Println("# TYPE lotus_miner_sector_sealing_deals_info gauge")
-	sectorList, err := storageMiner.SectorsList(context.Background())
+	// 本地扇区读取失败时只跳过依赖本地扇区的收集器
+	// when the local sectors fail to load, only the collectors that need them are skipped
+	sectors, err := loadSectorsCached(context.Background(), StartTime)
+	sectorsLoaded := err == nil
 	if err != nil {
 		fmt.Println("sectorList error", err)
-		return
 	}
-	for _, sector := range sectorList {
-		detail, err := storageMiner.SectorsStatus(context.Background(), sector, false)
+	// 链上扇区和 partition 每次只读取一次, 由下面的收集器共用
+	// the sectors and the partitions on chain are read once and shared by the collectors below
+	onChainSectors, err := fullNode.StateMinerSectors(context.Background(), minerId, nil, chainHead.Key())
+	if err != nil {
+		fmt.Println("onChainSectors error", err)
+	}
+	partitions, err := loadMinerPartitions(context.Background(), minerId, chainHead.Key())
+	if err != nil {
+		fmt.Println("partitions error", err)
+	}
+	if sectorsLoaded {
+		sectorProofs := chainSealProofs(onChainSectors)
+		newProof, err := miner.PreferredSealProofTypeFromWindowPoStType(daemonNetworkVersion, daemonStats.WindowPoStProofType)
 		if err != nil {
-			fmt.Println("sectorList error", err)
-			return
-		}
-		// 计算 0 出现在数组中的个数
-		a := 0
-		for _, j := range detail.Deals {
-			if j == 0 {
-				a++
-			}
-		}
-		deals := len(detail.Deals) - a
-		creationDate := detail.Log[0].Timestamp
-		packedDate := ""
-		finalizedDate := ""
-		verifiedWeight := detail.VerifiedDealWeight
-		for i := 0; i < len(detail.Log); i++ {
-			if detail.Log[i].Kind == "event;sealing.SectorPacked" {
-				packedDate = strconv.Itoa(int(detail.Log[i].Timestamp))
-			}
-			if detail.Log[i].Kind == "event;sealing.SectorFinalized" {
-				finalizedDate = strconv.Itoa(int(detail.Log[i].Timestamp))
-			}
-		}
-		var pledged int
-		if detail.Log[0].Kind == "event;sealing.SectorStartCC" {
-			pledged = 1
+			fmt.Println("newProof error", err)
 		} else {
-			pledged = 0
+			generateSectorSummary(minerId, minerHost, sectors, sectorProofs, newProof)
 		}
-		fmt.Print("lotus_miner_sector_state { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", sector_id=", `"`, sector, `"`, ", state=", `"`, detail.State, `"`, ", pledged=", `"`, pledged, `"`, ", deals=", `"`, deals, `"`, ", verified_weight=", `"`, verifiedWeight, `"`, " } 1\n")
+		generateSectors(minerId, minerHost, sectors)
+		generateDeals(context.Background(), minerId, minerHost, sectors, chainHead, StartTime)
+	}
 
-		if packedDate != "" {
-			fmt.Print("lotus_miner_sector_event { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", sector_id=", `"`, sector, `", event_type="packed" } `, packedDate, "\n")
-		}
-		if string(creationDate) != "" {
-			fmt.Print("lotus_miner_sector_event { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", sector_id=", `"`, sector, `", event_type="creation" } `, creationDate, "\n")
-		}
-		if finalizedDate != "" {
-			fmt.Print("lotus_miner_sector_event { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", sector_id=", `"`, sector, `", event_type="finalized" } `, finalizedDate, "\n")
-		}
+	// 生成封装阶段耗时
+	// GENERATE SEALING STAGE DURATIONS
+	if sectorsLoaded {
+		recordSectorWorkers(state, workerJobs, workerStats, sectors, StartTime)
+		generateSealingStages(minerId, minerHost, sectors, state, StartTime)
+	}
 
-		// 	// 这段for循环暂时无法测试到　TODO
-		list1 := [2]string{"Proving", "Removed"}
-		for _, j := range list1 {
-			if string(detail.State) != j {
-				for _, deal := range detail.Deals {
-					if deal != 0 {
-						var dealIsVerified, dealSize, dealSlashEpoch, dealPricePerEpoch, dealProviderCollateral, dealClientCollateral, dealStartEpoch, dealEndEpoch string
-						dealInfo, err := fullNode.StateMarketStorageDeal(context.Background(), deal, emptyTipSetKey)
-						if err != nil {
-							dealIsVerified = "unknown"
-							dealSize = "unknown"
-							dealSlashEpoch = "unknown"
-							dealPricePerEpoch = "unknown"
-							dealProviderCollateral = "unknown"
-							dealClientCollateral = "unknown"
-							dealStartEpoch = "unknown"
-							dealEndEpoch = "unknown"
-						} else {
-							dealIsVerified = strconv.FormatBool(dealInfo.Proposal.VerifiedDeal)
-							dealSize = string(dealInfo.Proposal.PieceSize)
-							dealSlashEpoch = string(dealInfo.State.SlashEpoch)
-							dealPricePerEpoch = dealInfo.Proposal.StoragePricePerEpoch.String()
-							dealProviderCollateral = dealInfo.Proposal.ProviderCollateral.String()
-							dealClientCollateral = dealInfo.Proposal.ClientCollateral.String()
-							dealStartEpoch = string(dealInfo.Proposal.StartEpoch)
-							dealEndEpoch = string(dealInfo.Proposal.EndEpoch)
-						}
-						fmt.Print("lotus_miner_sector_sealing_deals_size { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", sector_id=", `"`, sector, `"`, ", deal_id=", `"`, deal, `"`, ", deal_is_verified=", `"`, dealIsVerified, `"`, ", deal_slash_epoch=", `"`, dealSlashEpoch, `"`, ", deal_price_per_epoch=", `"`, dealPricePerEpoch, `"`, ",deal_provider_collateral=", `"`, dealProviderCollateral, `"`, ", deal_client_collateral=", `"`, dealClientCollateral, `"`, ", deal_size=", `"`, dealSize, `"`, ", deal_start_epoch=", `"`, dealStartEpoch, `"`, ", deal_end_epoch=", `"`, dealEndEpoch, `"`, " } 1\n")
-					}
-				}
-			}
+	// 检测卡住的扇区
+	// DETECT STUCK SECTORS
+	if sectorsLoaded {
+		generateStuckSectors(minerId, minerHost, sectors, StartTime)
+	}
+
+	// 生成封装失败统计
+	// GENERATE SEALING FAILURES
+	if sectorsLoaded {
+		accountSectorFailures(state, sectors)
+		generateSectorFailures(minerId, minerHost, sectors, state)
+	}
+
+	// 检查本地和链上扇区状态是否一致
+	// AUDIT LOCAL AND CHAIN SECTOR STATES
+	if sectorsLoaded && onChainSectors != nil && partitions != nil {
+		err = generateAudit(minerId, minerHost, sectors, onChainSectors, partitions)
+		if err != nil {
+			fmt.Println("audit error", err)
 		}
 	}
 
-	// GENERATE DEADLINES
-	provenPartitions, err := fullNode.StateMinerDeadlines(context.Background(), minerId, emptyTipSetKey)
-	if err != nil {
-		fmt.Println("provenPartitions error", err)
-		return
+	// 检查 PreCommit 过期风险
+	// CHECK PRE-COMMIT EXPIRY RISK
+	if sectorsLoaded {
+		generatePreCommits(context.Background(), minerId, minerHost, sectors, chainHead)
 	}
-	deadlines, err := fullNode.StateMinerProvingDeadline(context.Background(), minerId, emptyTipSetKey)
-	if err != nil {
-		fmt.Println("deadlines error", err)
-		return
+
+	// 预测存储空间耗尽时间
+	// FORECAST STORAGE EXHAUSTION
+	if sectorsLoaded {
+		generateStorageForecast(minerId, minerHost, storagePaths, finalizedTimes(sectors), daemonStats.SectorSize, StartTime)
 	}
-	dlEpoch, err := strconv.Atoi(deadlines.CurrentEpoch.String())
+
+	// 生成扇区到期预测
+	// GENERATE SECTOR EXPIRATIONS
+	if onChainSectors != nil && partitions != nil {
+		err = generateExpirations(minerId, minerHost, chainHead, daemonStats.SectorSize, onChainSectors, partitions)
+		if err != nil {
+			fmt.Println("expirations error", err)
+		}
+	}
+
+	// 检测漏掉的 WindowPoSt
+	// DETECT MISSED WINDOWPOSTS
+	err = trackMissedPoSts(context.Background(), minerId, chainHead, state)
 	if err != nil {
-		fmt.Println("dlEpoch error", err)
-		return
+		fmt.Println("missed wpost error", err)
 	}
-	dlIndex := deadlines.Index
-	dlOpen := deadlines.Open
-	dlNumbers := deadlines.WPoStPeriodDeadlines
-	dlWindow := deadlines.WPoStChallengeWindow
-	fmt.Println("# HELP lotus_miner_deadline_info deadlines and WPoSt informations")
-	fmt.Println("# TYPE lotus_miner_deadline_info gauge")
-	fmt.Print("lotus_miner_deadline_info { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", current_idx=", `"`, dlIndex, `"`, ", current_epoch=", `"`, dlEpoch, `"`, ",current_open_epoch=", `"`, dlOpen, `"`, ", wpost_period_deadlines=", `"`, dlNumbers, `"`, ", wpost_challenge_window=", `"`, dlWindow, `" } 1`, "\n")
-	fmt.Println("# HELP lotus_miner_deadline_active_start remaining time before deadline start")
-	fmt.Println("# TYPE lotus_miner_deadline_active_start gauge")
-	fmt.Println("# HELP lotus_miner_deadline_active_sectors_all number of sectors in the deadline")
-	fmt.Println("# TYPE lotus_miner_deadline_active_sectors_all gauge")
-	fmt.Println("# HELP lotus_miner_deadline_active_sectors_recovering number of sectors in recovering state")
-	fmt.Println("# TYPE lotus_miner_deadline_active_sectors_recovering gauge")
-	fmt.Println("# HELP lotus_miner_deadline_active_sectors_faulty number of faulty sectors")
-	fmt.Println("# TYPE lotus_miner_deadline_active_sectors_faulty gauge")
-	fmt.Println("# HELP lotus_miner_deadline_active_sectors_live number of live sectors")
-	fmt.Println("# TYPE lotus_miner_deadline_active_sectors_live gauge")
-	fmt.Println("# HELP lotus_miner_deadline_active_sectors_active number of active sectors")
-	fmt.Println("# TYPE lotus_miner_deadline_active_sectors_active gauge")
-	fmt.Println("# HELP lotus_miner_deadline_active_partitions number of partitions in the deadline")
-	fmt.Println("# TYPE lotus_miner_deadline_active_partitions gauge")
-	fmt.Println("# HELP lotus_miner_deadline_active_partitions_proven number of partitions already proven for the deadline")
-	fmt.Println("# TYPE lotus_miner_deadline_active_partitions_proven gauge")
-	for i := 0; i < int(dlNumbers); i++ {
-		idx := (int(dlIndex) + i) % int(dlNumbers)
-		opened := int(dlOpen) + int(dlWindow)*i
-		partitions, err := fullNode.StateMinerPartitions(context.Background(), minerId, uint64(idx), emptyTipSetKey)
+	generateMissedPoSts(minerId, minerHost, state)
+
+	// 跟踪扇区错误和恢复
+	// TRACK SECTOR FAULTS AND RECOVERIES
+	if partitions != nil {
+		err = trackFaults(context.Background(), minerId, chainHead, partitions, state)
 		if err != nil {
-			fmt.Println("partitions error", err)
-			return
+			fmt.Println("faults error", err)
+		} else {
+			generateFaults(minerId, minerHost, chainHead, state)
 		}
-		if partitions != nil {
-			faulty := 0
-			recovering := 0
-			alls := 0
-			active := 0
-			live := 0
-			count := len(partitions)
-			proven, err := provenPartitions[idx].PostSubmissions.Count()
-			if err != nil {
-				fmt.Println("proven error ", err)
-				return
-			}
+	}
 
-			for _, partition := range partitions {
-				a, err := partition.FaultySectors.Count()
-				if err != nil {
-					fmt.Println("proven error ", err)
-					return
-				}
-				faulty += int(a)
-
-				b, err := partition.RecoveringSectors.Count()
-				if err != nil {
-					fmt.Println("proven error ", err)
-					return
-				}
-				recovering += int(b)
-
-				c, err := partition.ActiveSectors.Count()
-				if err != nil {
-					fmt.Println("proven error ", err)
-					return
-				}
-				active += int(c)
-
-				d, err := partition.LiveSectors.Count()
-				if err != nil {
-					fmt.Println("proven error ", err)
-					return
-				}
-				live += int(d)
-
-				e, err := partition.AllSectors.Count()
-				if err != nil {
-					fmt.Println("proven error ", err)
-					return
-				}
-				alls = int(e)
+	// 生成存储市场交易流程
+	// GENERATE STORAGE MARKET PIPELINE
+	err = generateMarket(context.Background(), minerId, minerHost, StartTime)
+	if err != nil {
+		fmt.Println("market error", err)
+	}
 
-			}
-			fmt.Print("lotus_miner_deadline_active_start { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", index=", `"`, idx, `"`, " } ", (opened-dlEpoch)*30, "\n")
-			fmt.Print("lotus_miner_deadline_active_partitions_proven { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", index=", `"`, idx, `"`, " } ", proven, "\n")
-			fmt.Print("lotus_miner_deadline_active_partitions { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", index=", `"`, idx, `"`, " } ", count, "\n")
-			fmt.Print("lotus_miner_deadline_active_sectors_all { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", index=", `"`, idx, `"`, " } ", alls, "\n")
-			fmt.Print("lotus_miner_deadline_active_sectors_recovering { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", index=", `"`, idx, `"`, " } ", recovering, "\n")
-			fmt.Print("lotus_miner_deadline_active_sectors_faulty { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", index=", `"`, idx, `"`, " } ", faulty, "\n")
-			fmt.Print("lotus_miner_deadline_active_sectors_active { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", index=", `"`, idx, `"`, " } ", active, "\n")
-			fmt.Print("lotus_miner_deadline_active_sectors_live { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", index=", `"`, idx, `"`, " } ", live, "\n")
-		}
+	// 生成 DEADLINES
+	// GENERATE DEADLINES
+	if partitions == nil {
+		return
+	}
+	err = generateDeadlines(context.Background(), minerId, minerHost, chainHead.Key(), partitions)
+	if err != nil {
+		fmt.Println("deadlines error", err)
+		return
 	}
 }
 
@@ -541,6 +586,7 @@ func init() {
 		fmt.Println("getEnvPath error", err)
 		return
 	}
+	Config = *env
 	minerArr := strings.Split(env.MinerApiInfo, ":")
 	minerUrl, err := getCredentials(minerArr[1])
 	if err != nil {
@@ -579,7 +625,7 @@ func getEnvPath() (*Env, error) {
 	str = strings.ReplaceAll(str, "'", "\"")
 	str = strings.ReplaceAll(str, "#BEGIN GET ENV PATH", "")
 	str = strings.ReplaceAll(str, "#END GET ENV PATH", "")
-	var env Env
+	env := defaultEnv()
 	err = json.Unmarshal([]byte(str), &env)
 	if err != nil {
 		fmt.Println(err)
@@ -593,4 +639,74 @@ type Env struct {
 	MinerApiInfo   string `json:"MINER_API_INFO"`
 	LotusPath      string `json:"LOTUS_PATH"`
 	LotusMinerPath string `json:"LOTUS_MINER_PATH"`
+	// StatePath is the file used to keep state between two runs
+	StatePath string `json:"FARCASTER_STATE_PATH"`
+	// MpoolStuckAge is the time in seconds after which a pending local message is considered stuck
+	MpoolStuckAge int64 `json:"MPOOL_STUCK_AGE"`
+	// ChainScanStartHeight is the height the first scan of on chain messages starts at, the head when 0
+	ChainScanStartHeight int64 `json:"CHAIN_SCAN_START_HEIGHT"`
+	// ChainScanMaxEpochs is the maximum number of epochs scanned in one run, backfills span several runs
+	ChainScanMaxEpochs int64 `json:"CHAIN_SCAN_MAX_EPOCHS"`
+	// ListenAddress is the address of the details HTTP server started by "serve"
+	ListenAddress string `json:"FARCASTER_LISTEN_ADDRESS"`
+	// WPoStMinBalance is the balance in FIL under which an address able to send WindowPoSt is flagged
+	WPoStMinBalance float64 `json:"WPOST_MIN_BALANCE"`
+	// ForecastWindowDays is the number of days of finalized sectors the sealing rate is computed on
+	ForecastWindowDays float64 `json:"FORECAST_WINDOW_DAYS"`
+	// SectorLocationStates are the sector states whose file locations are exported, none when empty
+	SectorLocationStates []string `json:"SECTOR_LOCATION_STATES"`
+	// SectorDetails exports one lotus_miner_sector_state series per sector on top of the summary
+	SectorDetails bool `json:"SECTOR_DETAILS"`
+	// SealingStatsWindowDays is the number of days of sealing stages the durations are computed on
+	SealingStatsWindowDays float64 `json:"SEALING_STATS_WINDOW_DAYS"`
+	// SectorStuckThresholds maps a sector state to the duration (like "8h") after which a sector
+	// still in that state is stuck, the configured entries are added to the defaults
+	SectorStuckThresholds map[string]string `json:"SECTOR_STUCK_THRESHOLDS"`
+	// SectorCachePath is the file the sector statuses are cached in between two runs
+	SectorCachePath string `json:"SECTOR_CACHE_PATH"`
+	// SectorCacheRefresh is how long (like "6h") the status of a proven sector is cached
+	SectorCacheRefresh string `json:"SECTOR_CACHE_REFRESH"`
+	// SectorStatusWorkers is the number of SectorsStatus requests sent concurrently
+	SectorStatusWorkers int `json:"SECTOR_STATUS_WORKERS"`
+	// PreCommitDangerEpochs is the number of epochs before the pre-commit expiry a sector is counted in
+	// lotus_miner_precommit_danger
+	PreCommitDangerEpochs int64 `json:"PRECOMMIT_DANGER_EPOCHS"`
+	// DeadlinePartitions enables the per partition deadline series
+	DeadlinePartitions bool `json:"DEADLINE_PARTITIONS"`
+	// DeadlineTimezone is the time zone of the deadline schedule, a tz database name like UTC or Asia/Shanghai
+	DeadlineTimezone string `json:"DEADLINE_TIMEZONE"`
+	// DealSealingDuration is the expected time from the creation of a sector to the end of its sealing,
+	// used to tell if its deals start too early
+	DealSealingDuration string `json:"DEAL_SEALING_DURATION"`
+}
+
+func defaultEnv() Env {
+	return Env{
+		StatePath:              "/var/lib/lotus-farcaster/state.json",
+		MpoolStuckAge:          1800,
+		ChainScanMaxEpochs:     120,
+		ListenAddress:          "127.0.0.1:9101",
+		WPoStMinBalance:        1,
+		ForecastWindowDays:     7,
+		SealingStatsWindowDays: 7,
+		SectorStuckThresholds: map[string]string{
+			"Packing":        "2h",
+			"GetTicket":      "1h",
+			"PreCommit1":     "8h",
+			"PreCommit2":     "2h",
+			"PreCommitting":  "1h",
+			"PreCommitWait":  "1h",
+			"WaitSeed":       "2h",
+			"Committing":     "4h",
+			"SubmitCommit":   "1h",
+			"CommitWait":     "1h",
+			"FinalizeSector": "2h",
+		},
+		SectorCachePath:       "/var/lib/lotus-farcaster/sectors.json",
+		SectorCacheRefresh:    "6h",
+		SectorStatusWorkers:   8,
+		PreCommitDangerEpochs: 2880,
+		DeadlineTimezone:      "Local",
+		DealSealingDuration:   "24h",
+	}
 }
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+)
+
+// terminalDealStates 是交易的终止状态, 其他状态的交易还在流程中
+// terminalDealStates are the storage deal states a deal never leaves, deals in the other states are
+// still in the pipeline
+var terminalDealStates = map[storagemarket.StorageDealStatus]bool{
+	storagemarket.StorageDealUnknown:          true,
+	storagemarket.StorageDealProposalNotFound: true,
+	storagemarket.StorageDealProposalRejected: true,
+	storagemarket.StorageDealActive:           true,
+	storagemarket.StorageDealExpired:          true,
+	storagemarket.StorageDealSlashed:          true,
+	storagemarket.StorageDealError:            true,
+}
+
+// generateMarket 输出存储市场的交易流程和报价
+// generateMarket prints the local deals by storage market state, the bytes still in the pipeline, the
+// age of the oldest deal of every non terminal state, the deals published on chain and the current ask
+func generateMarket(ctx context.Context, minerId address.Address, minerHost string, now int64) error {
+	deals, err := storageMiner.MarketListIncompleteDeals(ctx)
+	if err != nil {
+		return fmt.Errorf("MarketListIncompleteDeals: %w", err)
+	}
+	count := map[storagemarket.StorageDealStatus]int{}
+	oldest := map[storagemarket.StorageDealStatus]int64{}
+	var inFlight uint64
+	for _, deal := range deals {
+		count[deal.State]++
+		if terminalDealStates[deal.State] {
+			continue
+		}
+		inFlight += uint64(deal.Proposal.PieceSize)
+		age := now - deal.CreationTime.Time().Unix()
+		if age > oldest[deal.State] {
+			oldest[deal.State] = age
+		}
+	}
+	var states []storagemarket.StorageDealStatus
+	for state := range storagemarket.DealStates {
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i] < states[j] })
+
+	fmt.Println("# HELP lotus_miner_market_deals number of local storage deals by storage market state")
+	fmt.Println("# TYPE lotus_miner_market_deals gauge")
+	for _, state := range states {
+		fmt.Print("lotus_miner_market_deals { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", state=", `"`, storagemarket.DealStates[state], `"`, " } ", count[state], "\n")
+	}
+	fmt.Println("# HELP lotus_miner_market_deal_oldest_seconds age in seconds of the oldest local deal in a non terminal state")
+	fmt.Println("# TYPE lotus_miner_market_deal_oldest_seconds gauge")
+	for _, state := range states {
+		if terminalDealStates[state] || count[state] == 0 {
+			continue
+		}
+		fmt.Print("lotus_miner_market_deal_oldest_seconds { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", state=", `"`, storagemarket.DealStates[state], `"`, " } ", oldest[state], "\n")
+	}
+	fmt.Println("# HELP lotus_miner_market_in_flight_bytes padded size of the local deals in a non terminal state")
+	fmt.Println("# TYPE lotus_miner_market_in_flight_bytes gauge")
+	fmt.Print("lotus_miner_market_in_flight_bytes { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, " } ", inFlight, "\n")
+
+	// 链上的交易: 已发布, 已激活, 已罚没
+	// deals on chain: published, active or slashed
+	chainDeals, err := storageMiner.MarketListDeals(ctx)
+	if err != nil {
+		return fmt.Errorf("MarketListDeals: %w", err)
+	}
+	chainCount := map[string]int{"published": 0, "active": 0, "slashed": 0}
+	for _, deal := range chainDeals {
+		switch {
+		case deal.State.SlashEpoch > -1:
+			chainCount["slashed"]++
+		case deal.State.SectorStartEpoch > -1:
+			chainCount["active"]++
+		default:
+			chainCount["published"]++
+		}
+	}
+	fmt.Println("# HELP lotus_miner_market_chain_deals number of deals of the miner on chain by status (published, active, slashed)")
+	fmt.Println("# TYPE lotus_miner_market_chain_deals gauge")
+	for _, status := range []string{"published", "active", "slashed"} {
+		fmt.Print("lotus_miner_market_chain_deals { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", status=", `"`, status, `"`, " } ", chainCount[status], "\n")
+	}
+
+	// 当前报价
+	// current ask
+	ask, err := storageMiner.MarketGetAsk(ctx)
+	if err != nil {
+		return fmt.Errorf("MarketGetAsk: %w", err)
+	}
+	if ask == nil || ask.Ask == nil {
+		return nil
+	}
+	fmt.Println("# HELP lotus_miner_market_ask_price storage ask price in FIL per GiB per epoch")
+	fmt.Println("# TYPE lotus_miner_market_ask_price gauge")
+	fmt.Print("lotus_miner_market_ask_price { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, " } ", toFIL(ask.Ask.Price), "\n")
+	fmt.Println("# HELP lotus_miner_market_ask_verified_price verified storage ask price in FIL per GiB per epoch")
+	fmt.Println("# TYPE lotus_miner_market_ask_verified_price gauge")
+	fmt.Print("lotus_miner_market_ask_verified_price { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, " } ", toFIL(ask.Ask.VerifiedPrice), "\n")
+	fmt.Println("# HELP lotus_miner_market_ask_min_piece_size_bytes minimum padded piece size accepted by the ask")
+	fmt.Println("# TYPE lotus_miner_market_ask_min_piece_size_bytes gauge")
+	fmt.Print("lotus_miner_market_ask_min_piece_size_bytes { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, " } ", uint64(ask.Ask.MinPieceSize), "\n")
+	fmt.Println("# HELP lotus_miner_market_ask_max_piece_size_bytes maximum padded piece size accepted by the ask")
+	fmt.Println("# TYPE lotus_miner_market_ask_max_piece_size_bytes gauge")
+	fmt.Print("lotus_miner_market_ask_max_piece_size_bytes { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, " } ", uint64(ask.Ask.MaxPieceSize), "\n")
+	return nil
+}
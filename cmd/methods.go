@@ -0,0 +1,46 @@
+package main
+
+import (
+	"reflect"
+	"strconv"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/market"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+)
+
+var (
+	minerMethodNames  = methodNames(miner.Methods)
+	marketMethodNames = methodNames(market.Methods)
+)
+
+// methodNames 把 specs-actors 的方法表转成 方法号 -> 方法名
+// methodNames turns a specs-actors method table into a method number to name map
+func methodNames(methods interface{}) map[abi.MethodNum]string {
+	names := map[abi.MethodNum]string{}
+	v := reflect.ValueOf(methods)
+	for i := 0; i < v.NumField(); i++ {
+		if num, ok := v.Field(i).Interface().(abi.MethodNum); ok {
+			names[num] = v.Type().Field(i).Name
+		}
+	}
+	return names
+}
+
+// methodName returns the name of the method called by a message sent to the miner or market actor
+func methodName(minerId, to address.Address, method abi.MethodNum) string {
+	if method == 0 {
+		return "Send"
+	}
+	var names map[abi.MethodNum]string
+	if to == minerId {
+		names = minerMethodNames
+	} else if to == market.Address {
+		names = marketMethodNames
+	}
+	if name, ok := names[method]; ok {
+		return name
+	}
+	return "method_" + strconv.Itoa(int(method))
+}
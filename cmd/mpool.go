@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// shortAddr 返回地址的缩写形式
+// shortAddr returns the abbreviated form of an address used in labels
+func shortAddr(addr address.Address) string {
+	s := addr.String()
+	if len(s) <= 10 {
+		return s
+	}
+	return s[0:5] + "..." + s[len(s)-5:]
+}
+
+// addrRole returns the role of a local address, or its short form when it has none
+func addrRole(roles map[address.Address]string, addr address.Address) string {
+	if role, ok := roles[addr]; ok {
+		return role
+	}
+	return shortAddr(addr)
+}
+
+// mpoolNonceGaps returns the number of nonces missing between the chain nonce and the highest
+// pending nonce, and the first missing nonce (math.MaxUint64 when there is no gap)
+func mpoolNonceGaps(chainNonce uint64, messages []*types.SignedMessage) (uint64, uint64) {
+	var nonces []uint64
+	for _, message := range messages {
+		nonces = append(nonces, message.Message.Nonce)
+	}
+	sort.Slice(nonces, func(i, j int) bool { return nonces[i] < nonces[j] })
+
+	var gaps uint64
+	firstGap := uint64(math.MaxUint64)
+	expected := chainNonce
+	for _, nonce := range nonces {
+		if nonce < expected {
+			// already included on chain or a replacement of the same nonce
+			continue
+		}
+		if nonce > expected {
+			gaps += nonce - expected
+			if firstGap == math.MaxUint64 {
+				firstGap = expected
+			}
+		}
+		expected = nonce + 1
+	}
+	return gaps, firstGap
+}
+
+// generateMpoolStuck 按地址分析本地消息
+// generateMpoolStuck analyses the local messages of every address: nonce gaps, pending time and
+// messages whose fee cap is below the current base fee
+func generateMpoolStuck(ctx context.Context, minerId address.Address, minerHost string, localMessages map[address.Address][]*types.SignedMessage, roles map[address.Address]string, baseFee types.BigInt, state *State, now int64) error {
+	fmt.Println("# HELP lotus_mpool_local_nonce nonce of the local address, source is either the chain or the mpool")
+	fmt.Println("# TYPE lotus_mpool_local_nonce gauge")
+	fmt.Println("# HELP lotus_mpool_local_nonce_gap number of nonces missing between the chain nonce and the highest pending nonce")
+	fmt.Println("# TYPE lotus_mpool_local_nonce_gap gauge")
+	fmt.Println("# HELP lotus_mpool_local_underpriced number of local messages whose gas fee cap is below the current base fee")
+	fmt.Println("# TYPE lotus_mpool_local_underpriced gauge")
+	fmt.Println("# HELP lotus_mpool_local_oldest_pending time in seconds the oldest local message has been pending")
+	fmt.Println("# TYPE lotus_mpool_local_oldest_pending gauge")
+	fmt.Println("# HELP lotus_mpool_local_stuck number of local messages that are underpriced, blocked by a nonce gap or pending for too long")
+	fmt.Println("# TYPE lotus_mpool_local_stuck gauge")
+
+	// 角色地址即使没有待处理消息也要输出
+	// role addresses are always exported so that alerts have a series to work with
+	for addr := range roles {
+		if _, ok := localMessages[addr]; !ok {
+			localMessages[addr] = nil
+		}
+	}
+
+	seen := map[string]bool{}
+	for addr, messages := range localMessages {
+		role := addrRole(roles, addr)
+		actor, err := fullNode.StateGetActor(ctx, addr, types.EmptyTSK)
+		if err != nil {
+			return fmt.Errorf("StateGetActor %s: %w", addr, err)
+		}
+		mpoolNonce, err := fullNode.MpoolGetNonce(ctx, addr)
+		if err != nil {
+			return fmt.Errorf("MpoolGetNonce %s: %w", addr, err)
+		}
+		gaps, firstGap := mpoolNonceGaps(actor.Nonce, messages)
+
+		underpriced := 0
+		stuck := 0
+		var oldest int64
+		for _, message := range messages {
+			if message.Message.Nonce < actor.Nonce {
+				continue
+			}
+			msgCid := message.Cid().String()
+			seen[msgCid] = true
+			firstSeen, ok := state.MpoolFirstSeen[msgCid]
+			if !ok {
+				firstSeen = now
+				state.MpoolFirstSeen[msgCid] = now
+			}
+			pending := now - firstSeen
+			if pending > oldest {
+				oldest = pending
+			}
+
+			isUnderpriced := types.BigCmp(message.Message.GasFeeCap, baseFee) < 0
+			if isUnderpriced {
+				underpriced++
+			}
+			if isUnderpriced || message.Message.Nonce > firstGap || pending > Config.MpoolStuckAge {
+				stuck++
+			}
+		}
+
+		fmt.Print("lotus_mpool_local_nonce { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", role=", `"`, role, `"`, ", address=", `"`, addr, `"`, `, source="chain" } `, actor.Nonce, "\n")
+		fmt.Print("lotus_mpool_local_nonce { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", role=", `"`, role, `"`, ", address=", `"`, addr, `"`, `, source="mpool" } `, mpoolNonce, "\n")
+		fmt.Print("lotus_mpool_local_nonce_gap { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", role=", `"`, role, `"`, ", address=", `"`, addr, `"`, " } ", gaps, "\n")
+		fmt.Print("lotus_mpool_local_underpriced { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", role=", `"`, role, `"`, ", address=", `"`, addr, `"`, " } ", underpriced, "\n")
+		fmt.Print("lotus_mpool_local_oldest_pending { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", role=", `"`, role, `"`, ", address=", `"`, addr, `"`, " } ", oldest, "\n")
+		fmt.Print("lotus_mpool_local_stuck { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", role=", `"`, role, `"`, ", address=", `"`, addr, `"`, " } ", stuck, "\n")
+	}
+
+	// 清理已经离开 mpool 的消息
+	// forget the messages that left the mpool
+	for msgCid := range state.MpoolFirstSeen {
+		if !seen[msgCid] {
+			delete(state.MpoolFirstSeen, msgCid)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+func TestMpoolNonceGaps(t *testing.T) {
+	tests := []struct {
+		chainNonce uint64
+		pending    []uint64
+		gaps       uint64
+		firstGap   uint64
+	}{
+		{5, nil, 0, math.MaxUint64},
+		{5, []uint64{5, 6, 7}, 0, math.MaxUint64},
+		{5, []uint64{7, 5, 6}, 0, math.MaxUint64},
+		{5, []uint64{6, 7}, 1, 5},
+		{5, []uint64{5, 8, 10}, 3, 6},
+		{5, []uint64{3, 4, 5}, 0, math.MaxUint64},
+		{5, []uint64{5, 5, 6}, 0, math.MaxUint64},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(fmt.Sprintf("%v", test), func(t *testing.T) {
+			var messages []*types.SignedMessage
+			for _, nonce := range test.pending {
+				messages = append(messages, &types.SignedMessage{Message: types.Message{Nonce: nonce}})
+			}
+			gaps, firstGap := mpoolNonceGaps(test.chainNonce, messages)
+			if gaps != test.gaps || firstGap != test.firstGap {
+				t.Errorf("got %d gaps from %d, want %d from %d", gaps, firstGap, test.gaps, test.firstGap)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/lotus/chain/actors/builtin"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// msigState 是 StateReadState 返回的多签状态中用到的字段
+// msigState holds the fields of the multisig actor state returned by StateReadState that farcaster uses
+type msigState struct {
+	Signers               []address.Address
+	NumApprovalsThreshold uint64
+}
+
+// isMultisig tells if the actor behind the address is a multisig
+func isMultisig(ctx context.Context, addr address.Address) (bool, error) {
+	actor, err := fullNode.StateGetActor(ctx, addr, types.EmptyTSK)
+	if err != nil {
+		return false, fmt.Errorf("StateGetActor %s: %w", addr, err)
+	}
+	return builtin.IsMultisigActor(actor.Code), nil
+}
+
+// accountKeyOrID 返回地址的 account key, 多签没有 account key, 返回 ID 地址
+// accountKeyOrID returns the account key of the address, or the address itself for a multisig, which
+// has no account key
+func accountKeyOrID(ctx context.Context, addr address.Address, multisig bool) (address.Address, error) {
+	if multisig {
+		return addr, nil
+	}
+	key, err := fullNode.StateAccountKey(ctx, addr, types.EmptyTSK)
+	if err != nil {
+		return address.Undef, fmt.Errorf("StateAccountKey %s: %w", addr, err)
+	}
+	return key, nil
+}
+
+// generateMultisig 输出多签地址的余额, 锁定和释放金额, 签名人, 门限和待处理交易数
+// generateMultisig prints the balance, locked and vested amounts, signers, approval threshold and
+// number of pending transactions of a multisig used by the miner
+func generateMultisig(ctx context.Context, minerId address.Address, minerHost string, role string, msig address.Address) error {
+	head, err := fullNode.ChainHead(ctx)
+	if err != nil {
+		return fmt.Errorf("ChainHead: %w", err)
+	}
+	actorState, err := fullNode.StateReadState(ctx, msig, head.Key())
+	if err != nil {
+		return fmt.Errorf("StateReadState %s: %w", msig, err)
+	}
+	raw, err := json.Marshal(actorState.State)
+	if err != nil {
+		return err
+	}
+	var st msigState
+	if err := json.Unmarshal(raw, &st); err != nil {
+		return fmt.Errorf("multisig state %s: %w", msig, err)
+	}
+	available, err := fullNode.MsigGetAvailableBalance(ctx, msig, head.Key())
+	if err != nil {
+		return fmt.Errorf("MsigGetAvailableBalance %s: %w", msig, err)
+	}
+	vesting, err := fullNode.MsigGetVestingSchedule(ctx, msig, head.Key())
+	if err != nil {
+		return fmt.Errorf("MsigGetVestingSchedule %s: %w", msig, err)
+	}
+	vested := types.NewInt(0)
+	if vesting.UnlockDuration > 0 && vesting.StartEpoch < head.Height() {
+		start, err := fullNode.ChainGetTipSetByHeight(ctx, vesting.StartEpoch, head.Key())
+		if err != nil {
+			return fmt.Errorf("ChainGetTipSetByHeight %d: %w", vesting.StartEpoch, err)
+		}
+		vested, err = fullNode.MsigGetVested(ctx, msig, start.Key(), head.Key())
+		if err != nil {
+			return fmt.Errorf("MsigGetVested %s: %w", msig, err)
+		}
+	}
+	pending, err := fullNode.MsigGetPending(ctx, msig, head.Key())
+	if err != nil {
+		return fmt.Errorf("MsigGetPending %s: %w", msig, err)
+	}
+	locked := types.BigSub(actorState.Balance, available)
+
+	var signers []string
+	for _, signer := range st.Signers {
+		signers = append(signers, signer.String())
+	}
+
+	fmt.Println("# HELP lotus_miner_msig_info multisig used by the miner, value is the number of approvals required")
+	fmt.Println("# TYPE lotus_miner_msig_info gauge")
+	fmt.Println("# HELP lotus_miner_msig_signers number of signers of the multisig")
+	fmt.Println("# TYPE lotus_miner_msig_signers gauge")
+	fmt.Println("# HELP lotus_miner_msig_balance balance of the multisig")
+	fmt.Println("# TYPE lotus_miner_msig_balance gauge")
+	fmt.Println("# HELP lotus_miner_msig_available balance of the multisig available for spending")
+	fmt.Println("# TYPE lotus_miner_msig_available gauge")
+	fmt.Println("# HELP lotus_miner_msig_locked balance of the multisig still locked by vesting")
+	fmt.Println("# TYPE lotus_miner_msig_locked gauge")
+	fmt.Println("# HELP lotus_miner_msig_vested amount vested since the multisig vesting started")
+	fmt.Println("# TYPE lotus_miner_msig_vested gauge")
+	fmt.Println("# HELP lotus_miner_msig_pending_transactions number of multisig transactions waiting for approvals")
+	fmt.Println("# TYPE lotus_miner_msig_pending_transactions gauge")
+	fmt.Print("lotus_miner_msig_info { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", role=", `"`, role, `"`, ", address=", `"`, msig, `"`, ", signers=", `"`, strings.Join(signers, ","), `"`, " } ", st.NumApprovalsThreshold, "\n")
+	fmt.Print("lotus_miner_msig_signers { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", role=", `"`, role, `"`, ", address=", `"`, msig, `"`, " } ", len(st.Signers), "\n")
+	fmt.Print("lotus_miner_msig_balance { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", role=", `"`, role, `"`, ", address=", `"`, msig, `"`, " } ", toFIL(actorState.Balance), "\n")
+	fmt.Print("lotus_miner_msig_available { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", role=", `"`, role, `"`, ", address=", `"`, msig, `"`, " } ", toFIL(available), "\n")
+	fmt.Print("lotus_miner_msig_locked { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", role=", `"`, role, `"`, ", address=", `"`, msig, `"`, " } ", toFIL(locked), "\n")
+	fmt.Print("lotus_miner_msig_vested { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", role=", `"`, role, `"`, ", address=", `"`, msig, `"`, " } ", toFIL(vested), "\n")
+	fmt.Print("lotus_miner_msig_pending_transactions { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", role=", `"`, role, `"`, ", address=", `"`, msig, `"`, " } ", len(pending), "\n")
+	return nil
+}
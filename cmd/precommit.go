@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+	miner3 "github.com/filecoin-project/specs-actors/v3/actors/builtin/miner"
+)
+
+// preCommitStates 是已经提交 PreCommit 但还没有 ProveCommit 上链的状态
+// preCommitStates are the sealing states of a sector pre-committed but not yet prove-committed
+var preCommitStates = []string{"PreCommitWait", "WaitSeed", "Committing", "SubmitCommit", "CommitWait", "ComputeProofFailed", "CommitFailed"}
+
+// preCommitNotFound 是 lotus 在 PreCommit 不在链上时返回的错误
+// preCommitNotFound is the error lotus returns when the pre-commit is not on chain
+const preCommitNotFound = "precommit info is not exists"
+
+// maxProveCommitDuration 是 v3 actors 的 ProveCommit 期限. 不用 lotus 按网络版本选择的 policy,
+// 因为它在更新的网络版本上会 panic
+// maxProveCommitDuration is the prove-commit window of the v3 actors. It is pinned instead of picked
+// by the lotus policy helpers from the network version, because those panic on newer network versions.
+var maxProveCommitDuration = miner3.MaxProveCommitDuration
+
+// generatePreCommits 输出 PreCommit 押金和离 ProveCommit 截止的高度差
+// generatePreCommits prints the pre-commit deposit locked in each state and the number of epochs left
+// before the pre-commit expires and its deposit is lost. Sectors whose pre-commit message is not on
+// chain yet are ignored, the other errors are logged and the sector skipped.
+func generatePreCommits(ctx context.Context, minerId address.Address, minerHost string, sectors []api.SectorInfo, head *types.TipSet) {
+	count := map[string]int{}
+	deposit := map[string]abi.TokenAmount{}
+	minLeft := map[string]abi.ChainEpoch{}
+	danger := 0
+
+	fmt.Println("# HELP lotus_miner_precommit_expiry_epochs epochs left before the pre-commit of the sector expires")
+	fmt.Println("# TYPE lotus_miner_precommit_expiry_epochs gauge")
+	for _, state := range preCommitStates {
+		deposit[state] = big.Zero()
+	}
+	for _, detail := range sectors {
+		state := string(detail.State)
+		if _, ok := deposit[state]; !ok {
+			continue
+		}
+		info, err := fullNode.StateSectorPreCommitInfo(ctx, minerId, detail.SectorID, head.Key())
+		if err != nil {
+			if !strings.Contains(err.Error(), preCommitNotFound) {
+				fmt.Println("StateSectorPreCommitInfo error", detail.SectorID, err)
+			}
+			continue
+		}
+		duration, ok := maxProveCommitDuration[info.Info.SealProof]
+		if !ok {
+			fmt.Println("maxProveCommitDuration error", detail.SectorID, "unknown seal proof", info.Info.SealProof)
+			continue
+		}
+		expiry := info.PreCommitEpoch + duration
+		left := expiry - head.Height()
+		count[state]++
+		deposit[state] = big.Add(deposit[state], info.PreCommitDeposit)
+		if current, ok := minLeft[state]; !ok || left < current {
+			minLeft[state] = left
+		}
+		if int64(left) <= Config.PreCommitDangerEpochs {
+			danger++
+		}
+		if Config.SectorDetails {
+			fmt.Print("lotus_miner_precommit_expiry_epochs { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", sector_id=", `"`, detail.SectorID, `"`, ", state=", `"`, state, `"`, " } ", left, "\n")
+		}
+	}
+
+	fmt.Println("# HELP lotus_miner_precommit_sectors number of sectors pre-committed on chain by sealing state")
+	fmt.Println("# TYPE lotus_miner_precommit_sectors gauge")
+	fmt.Println("# HELP lotus_miner_precommit_deposit pre-commit deposit in FIL locked by the sectors of the sealing state")
+	fmt.Println("# TYPE lotus_miner_precommit_deposit gauge")
+	fmt.Println("# HELP lotus_miner_precommit_expiry_epochs_min epochs left before the first pre-commit of the sealing state expires")
+	fmt.Println("# TYPE lotus_miner_precommit_expiry_epochs_min gauge")
+	for _, state := range preCommitStates {
+		fmt.Print("lotus_miner_precommit_sectors { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", state=", `"`, state, `"`, " } ", count[state], "\n")
+		fmt.Print("lotus_miner_precommit_deposit { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", state=", `"`, state, `"`, " } ", toFIL(deposit[state]), "\n")
+		if left, ok := minLeft[state]; ok {
+			fmt.Print("lotus_miner_precommit_expiry_epochs_min { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", state=", `"`, state, `"`, " } ", left, "\n")
+		}
+	}
+
+	fmt.Println("# HELP lotus_miner_precommit_danger number of pre-committed sectors expiring within PRECOMMIT_DANGER_EPOCHS epochs")
+	fmt.Println("# TYPE lotus_miner_precommit_danger gauge")
+	fmt.Print("lotus_miner_precommit_danger { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", threshold=", `"`, Config.PreCommitDangerEpochs, `"`, " } ", danger, "\n")
+}
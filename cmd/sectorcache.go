@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/api"
+)
+
+// cachedStates 是可以缓存的稳定状态, 其他状态 (包括 Faulty, Terminating 等) 每次都重新获取
+// cachedStates are the steady states whose status can come from the cache, sectors in any other
+// state, Faulty or Terminating included, are fetched on every run
+var cachedStates = map[api.SectorState]bool{
+	"Proving":      true,
+	"Removed":      true,
+	"FaultedFinal": true,
+}
+
+// cachedSector 是缓存的扇区状态和获取时间
+// cachedSector is a cached sector status with the unix time it was fetched at
+type cachedSector struct {
+	Fetched int64
+	Info    api.SectorInfo
+}
+
+// fetchSectors 用 SECTOR_STATUS_WORKERS 个并发请求获取扇区状态
+// fetchSectors gets the status of the sectors with at most SECTOR_STATUS_WORKERS requests in flight
+func fetchSectors(ctx context.Context, numbers []abi.SectorNumber) (map[abi.SectorNumber]api.SectorInfo, error) {
+	workers := Config.SectorStatusWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var lk sync.Mutex
+	var firstErr error
+	result := make(map[abi.SectorNumber]api.SectorInfo, len(numbers))
+	todo := make(chan abi.SectorNumber)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for sector := range todo {
+				detail, err := storageMiner.SectorsStatus(ctx, sector, false)
+				lk.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = fmt.Errorf("SectorsStatus %d: %w", sector, err)
+					cancel()
+				}
+				if err == nil {
+					// the traces are never used and make most of the size of the log
+					for i := range detail.Log {
+						detail.Log[i].Trace = ""
+					}
+					result[sector] = detail
+				}
+				lk.Unlock()
+			}
+		}()
+	}
+	for _, sector := range numbers {
+		select {
+		case todo <- sector:
+		case <-ctx.Done():
+		}
+	}
+	close(todo)
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return result, ctx.Err()
+}
+
+// sortedSectors returns the sectors of the map ordered by number
+func sortedSectors(byNumber map[abi.SectorNumber]api.SectorInfo) []api.SectorInfo {
+	sectors := make([]api.SectorInfo, 0, len(byNumber))
+	for _, detail := range byNumber {
+		sectors = append(sectors, detail)
+	}
+	sort.Slice(sectors, func(i, j int) bool { return sectors[i].SectorID < sectors[j].SectorID })
+	return sectors
+}
+
+// loadSectorCache reads the sector cache file, a missing or unreadable file gives an empty cache
+func loadSectorCache(path string) map[abi.SectorNumber]*cachedSector {
+	cache := map[abi.SectorNumber]*cachedSector{}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return cache
+	}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return map[abi.SectorNumber]*cachedSector{}
+	}
+	return cache
+}
+
+// saveSectorCache writes the sector cache file atomically
+func saveSectorCache(path string, cache map[abi.SectorNumber]*cachedSector) error {
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// loadSectorsCached 读取扇区状态, 稳定状态的扇区只在缓存超过 SECTOR_CACHE_REFRESH 后刷新,
+// 其他扇区每次都获取
+// loadSectorsCached returns the status of every sector like loadSectors, but sectors in one of the
+// cachedStates come from the cache until their entry is older than SECTOR_CACHE_REFRESH. The other
+// sectors are fetched on every run.
+func loadSectorsCached(ctx context.Context, now int64) ([]api.SectorInfo, error) {
+	refresh, err := time.ParseDuration(Config.SectorCacheRefresh)
+	if err != nil {
+		return nil, fmt.Errorf("SECTOR_CACHE_REFRESH: %w", err)
+	}
+	refreshSecs := int64(refresh.Seconds())
+	sectorList, err := storageMiner.SectorsList(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("SectorsList: %w", err)
+	}
+
+	cache := loadSectorCache(Config.SectorCachePath)
+	var due []abi.SectorNumber
+	for _, sector := range sectorList {
+		cached, ok := cache[sector]
+		if !ok || !cachedStates[cached.Info.State] || now-cached.Fetched >= refreshSecs {
+			due = append(due, sector)
+		}
+	}
+	fetched, err := fetchSectors(ctx, due)
+	if err != nil {
+		return nil, err
+	}
+
+	current := map[abi.SectorNumber]*cachedSector{}
+	sectors := map[abi.SectorNumber]api.SectorInfo{}
+	for _, sector := range sectorList {
+		if detail, ok := fetched[sector]; ok {
+			fetchedAt := now
+			if _, wasCached := cache[sector]; !wasCached && refreshSecs > 0 {
+				// 第一次缓存时错开刷新时间, 避免所有扇区同时过期
+				// spread the first refresh so that all the sectors don't expire in the same run
+				fetchedAt = now - int64(sector)%refreshSecs
+			}
+			current[sector] = &cachedSector{Fetched: fetchedAt, Info: detail}
+		} else {
+			current[sector] = cache[sector]
+		}
+		sectors[sector] = current[sector].Info
+	}
+	if err := saveSectorCache(Config.SectorCachePath, current); err != nil {
+		fmt.Println("saveSectorCache error", err)
+	}
+	return sortedSectors(sectors), nil
+}
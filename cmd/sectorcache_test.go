@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/api"
+)
+
+// benchSectors 是基准测试中假矿工的扇区数
+// benchSectors is the number of sectors of the fake miner used by the benchmarks
+const benchSectors = 100000
+
+// fakeMiner 是只实现了扇区列表和状态的 StorageMiner
+// fakeMiner is a StorageMiner answering SectorsList and SectorsStatus, every other method panics
+type fakeMiner struct {
+	api.StorageMiner
+	sectors []abi.SectorNumber
+	// delay is the simulated latency of a SectorsStatus call
+	delay time.Duration
+	// states overrides the Proving state of some sectors
+	states map[abi.SectorNumber]api.SectorState
+	// calls counts the SectorsStatus calls
+	calls int64
+}
+
+func newFakeMiner(n int, delay time.Duration) *fakeMiner {
+	m := &fakeMiner{delay: delay}
+	for i := 0; i < n; i++ {
+		m.sectors = append(m.sectors, abi.SectorNumber(i))
+	}
+	return m
+}
+
+func (m *fakeMiner) SectorsList(ctx context.Context) ([]abi.SectorNumber, error) {
+	return m.sectors, nil
+}
+
+// SectorsStatus returns a proving sector with a log the size of a real one
+func (m *fakeMiner) SectorsStatus(ctx context.Context, sector abi.SectorNumber, showOnChainInfo bool) (api.SectorInfo, error) {
+	atomic.AddInt64(&m.calls, 1)
+	if m.delay > 0 {
+		time.Sleep(m.delay)
+	}
+	info := api.SectorInfo{SectorID: sector, State: "Proving", Deals: []abi.DealID{0}}
+	if state, ok := m.states[sector]; ok {
+		info.State = state
+	}
+	start := uint64(1600000000 + int(sector)*60)
+	for i, kind := range []string{
+		"event;sealing.SectorStartCC", "event;sealing.SectorPacked", "event;sealing.SectorTicket",
+		"event;sealing.SectorPreCommit1", "event;sealing.SectorPreCommit2", "event;sealing.SectorPreCommitted",
+		"event;sealing.SectorPreCommitLanded", "event;sealing.SectorSeedReady", "event;sealing.SectorCommitted",
+		"event;sealing.SectorProving", "event;sealing.SectorFinalized",
+	} {
+		info.Log = append(info.Log, api.SectorLog{
+			Kind:      kind,
+			Timestamp: start + uint64(i)*3600,
+			Trace:     "trace that fetchSectors strips",
+			Message:   fmt.Sprintf("%s message of sector %d", kind, sector),
+		})
+	}
+	return info, nil
+}
+
+func TestLoadSectorsCached(t *testing.T) {
+	Config = defaultEnv()
+	Config.SectorCachePath = filepath.Join(t.TempDir(), "sectors.json")
+	Config.SectorCacheRefresh = "1h"
+	Config.SectorStatusWorkers = 4
+	m := newFakeMiner(10, 0)
+	m.states = map[abi.SectorNumber]api.SectorState{5: "PreCommit1"}
+	storageMiner = m
+	start := int64(1600000000)
+
+	// 每一步是一次运行, 第一次缓存的扇区 n 的获取时间提前 n 秒
+	// every step is one run, a sector n cached for the first time is recorded as fetched n seconds early
+	tests := []struct {
+		name    string
+		now     int64
+		sectors int
+		fetched []abi.SectorNumber
+	}{
+		{"first run fetches every sector", start, 10, []abi.SectorNumber{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}},
+		{"only the sealing sector is fetched", start + 1, 10, []abi.SectorNumber{5}},
+		{"first refresh is staggered", start + 3597, 10, []abi.SectorNumber{3, 4, 5, 6, 7, 8, 9}},
+		{"removed sectors are dropped", start + 3598, 8, []abi.SectorNumber{2, 5}},
+		{"refreshed entries wait a full period", start + 3600 + 3596, 8, []abi.SectorNumber{0, 1, 5}},
+	}
+	for _, test := range tests {
+		m.sectors = m.sectors[:test.sectors]
+		m.calls = 0
+		sectors, err := loadSectorsCached(context.Background(), test.now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sectors) != test.sectors {
+			t.Errorf("%s: got %d sectors, want %d", test.name, len(sectors), test.sectors)
+		}
+		if m.calls != int64(len(test.fetched)) {
+			t.Errorf("%s: %d SectorsStatus calls, want %d", test.name, m.calls, len(test.fetched))
+		}
+		cache := loadSectorCache(Config.SectorCachePath)
+		if len(cache) != test.sectors {
+			t.Errorf("%s: %d sectors cached, want %d", test.name, len(cache), test.sectors)
+		}
+		for _, sector := range test.fetched {
+			if entry := cache[sector]; entry == nil || entry.Fetched > test.now || entry.Fetched < test.now-int64(sector) {
+				t.Errorf("%s: sector %d not fetched by this run: %+v", test.name, sector, entry)
+			}
+		}
+	}
+}
+
+func benchmarkConfig(b *testing.B, workers int) {
+	Config = defaultEnv()
+	Config.SectorCachePath = filepath.Join(b.TempDir(), "sectors.json")
+	Config.SectorStatusWorkers = workers
+}
+
+func BenchmarkFetchSectors(b *testing.B) {
+	for _, workers := range []int{1, 8, 32} {
+		b.Run(fmt.Sprint("workers=", workers), func(b *testing.B) {
+			benchmarkConfig(b, workers)
+			m := newFakeMiner(benchSectors, 0)
+			storageMiner = m
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := fetchSectors(context.Background(), m.sectors); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkFetchSectorsLatency simulates a 50µs SectorsStatus call to show the effect of the pool size,
+// a single worker is left out as it takes minutes per run
+func BenchmarkFetchSectorsLatency(b *testing.B) {
+	for _, workers := range []int{8, 32, 64} {
+		b.Run(fmt.Sprint("workers=", workers), func(b *testing.B) {
+			benchmarkConfig(b, workers)
+			m := newFakeMiner(benchSectors, 50*time.Microsecond)
+			storageMiner = m
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := fetchSectors(context.Background(), m.sectors); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkLoadSectorsCachedCold measures a first run: every sector is fetched and the cache written
+func BenchmarkLoadSectorsCachedCold(b *testing.B) {
+	benchmarkConfig(b, 8)
+	storageMiner = newFakeMiner(benchSectors, 0)
+	now := time.Now().Unix()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		Config.SectorCachePath = filepath.Join(b.TempDir(), "sectors.json")
+		b.StartTimer()
+		if _, err := loadSectorsCached(context.Background(), now); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkLoadSectorsCachedWarm measures a run where every sector comes from the cache, the cost is
+// reading and rewriting the whole cache file
+func BenchmarkLoadSectorsCachedWarm(b *testing.B) {
+	benchmarkConfig(b, 8)
+	Config.SectorCacheRefresh = "1000h"
+	storageMiner = newFakeMiner(benchSectors, 0)
+	now := time.Now().Unix()
+	if _, err := loadSectorsCached(context.Background(), now); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := loadSectorsCached(context.Background(), now); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkSectorCacheFile measures loading and marshalling the full cache file alone
+func BenchmarkSectorCacheFile(b *testing.B) {
+	benchmarkConfig(b, 8)
+	m := newFakeMiner(benchSectors, 0)
+	storageMiner = m
+	fetched, err := fetchSectors(context.Background(), m.sectors)
+	if err != nil {
+		b.Fatal(err)
+	}
+	cache := map[abi.SectorNumber]*cachedSector{}
+	for sector, detail := range fetched {
+		cache[sector] = &cachedSector{Fetched: 1, Info: detail}
+	}
+	if err := saveSectorCache(Config.SectorCachePath, cache); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		loaded := loadSectorCache(Config.SectorCachePath)
+		if len(loaded) != benchSectors {
+			b.Fatalf("loaded %d sectors", len(loaded))
+		}
+		if err := saveSectorCache(Config.SectorCachePath, loaded); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
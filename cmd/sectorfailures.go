@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+)
+
+const sectorEventPrefix = "event;sealing.Sector"
+
+// failureTasks 是失败事件对应的 worker 任务, 其他失败发生在链上, 没有 worker
+// failureTasks maps a failure event to the worker task that failed, the other failures happen on chain
+// and have no worker
+var failureTasks = map[string]sealtasks.TaskType{
+	"AddPieceFailed":       sealtasks.TTAddPiece,
+	"SealPreCommit1Failed": sealtasks.TTPreCommit1,
+	"SealPreCommit2Failed": sealtasks.TTPreCommit2,
+	"ComputeProofFailed":   sealtasks.TTCommit2,
+	"FinalizeFailed":       sealtasks.TTFinalize,
+}
+
+// SectorFailures 是某种失败在某个 worker 上出现的次数
+// SectorFailures counts the sealing failures of one kind on one worker host
+type SectorFailures struct {
+	Kind       string
+	WorkerHost string
+	Count      int64
+}
+
+// sectorEventKind returns the event of a log entry without its prefix, "" when it isn't an event
+func sectorEventKind(entry api.SectorLog) string {
+	if !strings.HasPrefix(entry.Kind, sectorEventPrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(entry.Kind, sectorEventPrefix)
+}
+
+// sectorRetries returns the number of retry events in the sector log
+func sectorRetries(detail api.SectorInfo) int {
+	retries := 0
+	for _, entry := range detail.Log {
+		if strings.HasPrefix(sectorEventKind(entry), "Retry") {
+			retries++
+		}
+	}
+	return retries
+}
+
+// accountSectorFailures 统计新出现在扇区日志中的失败事件.
+// 日志只会追加, 所以记录每个扇区已经统计过的失败数. 第一次运行只记录基线, 不计数
+// accountSectorFailures counts the failure events that appeared in the sector logs since the last run.
+// Logs are append only, so the number of failures already counted is kept per sector. The first run
+// only records this baseline and counts nothing.
+func accountSectorFailures(state *State, sectors []api.SectorInfo) {
+	baseline := !state.SectorFailuresBaseline
+	seen := map[string]int{}
+	for _, detail := range sectors {
+		sector := strconv.FormatUint(uint64(detail.SectorID), 10)
+		failures := 0
+		for _, entry := range detail.Log {
+			kind := sectorEventKind(entry)
+			if !strings.HasSuffix(kind, "Failed") {
+				continue
+			}
+			failures++
+			if baseline || failures <= state.SectorFailuresSeen[sector] {
+				continue
+			}
+			workerHost := ""
+			if task := failureTasks[kind]; task != "" {
+				workerHost = "unknown"
+				if host, ok := state.SectorWorkers[sectorWorkerKey(uint64(detail.SectorID), task)]; ok {
+					workerHost = host
+				}
+			}
+			key := kind + ";" + workerHost
+			if _, ok := state.SectorFailures[key]; !ok {
+				state.SectorFailures[key] = &SectorFailures{Kind: kind, WorkerHost: workerHost}
+			}
+			state.SectorFailures[key].Count++
+		}
+		if failures > 0 {
+			seen[sector] = failures
+		}
+	}
+	state.SectorFailuresSeen = seen
+	state.SectorFailuresBaseline = true
+}
+
+// generateSectorFailures 输出封装失败计数, 失败状态的扇区数和每个扇区的重试次数
+// generateSectorFailures prints the sealing failure counters, the number of sectors in a failed state
+// and the retries of the sectors not proven yet
+func generateSectorFailures(minerId address.Address, minerHost string, sectors []api.SectorInfo, state *State) {
+	fmt.Println("# HELP lotus_miner_sector_failures_total number of sealing failures by kind and worker host")
+	fmt.Println("# TYPE lotus_miner_sector_failures_total counter")
+	var keys []string
+	for key := range state.SectorFailures {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		f := state.SectorFailures[key]
+		fmt.Print("lotus_miner_sector_failures_total { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", kind=", `"`, f.Kind, `"`, ", worker_host=", `"`, f.WorkerHost, `"`, " } ", f.Count, "\n")
+	}
+
+	failed := map[api.SectorState]int{}
+	fmt.Println("# HELP lotus_miner_sector_retries number of retries of the sectors not proven yet")
+	fmt.Println("# TYPE lotus_miner_sector_retries gauge")
+	for _, detail := range sectors {
+		if strings.HasSuffix(string(detail.State), "Failed") {
+			failed[detail.State]++
+		}
+		if provenStates[detail.State] {
+			continue
+		}
+		if retries := sectorRetries(detail); retries > 0 {
+			fmt.Print("lotus_miner_sector_retries { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", sector_id=", `"`, detail.SectorID, `"`, ", state=", `"`, detail.State, `"`, " } ", retries, "\n")
+		}
+	}
+
+	var states []string
+	for s := range failed {
+		states = append(states, string(s))
+	}
+	sort.Strings(states)
+	fmt.Println("# HELP lotus_miner_sector_failed number of sectors currently in a failed state")
+	fmt.Println("# TYPE lotus_miner_sector_failed gauge")
+	for _, s := range states {
+		fmt.Print("lotus_miner_sector_failed { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", state=", `"`, s, `"`, " } ", failed[api.SectorState(s)], "\n")
+	}
+}
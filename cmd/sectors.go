@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+)
+
+// provenStates 是扇区在链上证明之后的状态
+// provenStates are the states a sector can be in once it has been proven on chain
+var provenStates = map[api.SectorState]bool{
+	"Proving":           true,
+	"Faulty":            true,
+	"FaultReported":     true,
+	"FaultedFinal":      true,
+	"Terminating":       true,
+	"TerminateWait":     true,
+	"TerminateFinality": true,
+	"TerminateFailed":   true,
+	"Removing":          true,
+	"RemoveFailed":      true,
+	"Removed":           true,
+}
+
+var sealProofNames = map[abi.RegisteredSealProof]string{
+	abi.RegisteredSealProof_StackedDrg2KiBV1:     "2KiBV1",
+	abi.RegisteredSealProof_StackedDrg8MiBV1:     "8MiBV1",
+	abi.RegisteredSealProof_StackedDrg512MiBV1:   "512MiBV1",
+	abi.RegisteredSealProof_StackedDrg32GiBV1:    "32GiBV1",
+	abi.RegisteredSealProof_StackedDrg64GiBV1:    "64GiBV1",
+	abi.RegisteredSealProof_StackedDrg2KiBV1_1:   "2KiBV1_1",
+	abi.RegisteredSealProof_StackedDrg8MiBV1_1:   "8MiBV1_1",
+	abi.RegisteredSealProof_StackedDrg512MiBV1_1: "512MiBV1_1",
+	abi.RegisteredSealProof_StackedDrg32GiBV1_1:  "32GiBV1_1",
+	abi.RegisteredSealProof_StackedDrg64GiBV1_1:  "64GiBV1_1",
+}
+
+// sealProofName returns the short name of a seal proof type
+func sealProofName(proof abi.RegisteredSealProof) string {
+	if name, ok := sealProofNames[proof]; ok {
+		return name
+	}
+	return strconv.Itoa(int(proof))
+}
+
+// loadSectors 读取全部扇区的状态
+// loadSectors returns the status of every sector known to the sealing FSM, ordered by number
+func loadSectors(ctx context.Context) ([]api.SectorInfo, error) {
+	sectorList, err := storageMiner.SectorsList(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("SectorsList: %w", err)
+	}
+	sectors, err := fetchSectors(ctx, sectorList)
+	if err != nil {
+		return nil, err
+	}
+	return sortedSectors(sectors), nil
+}
+
+// chainSealProofs 返回链上扇区的证明类型
+// chainSealProofs returns the seal proof type of every sector on chain
+func chainSealProofs(onChain []*miner.SectorOnChainInfo) map[abi.SectorNumber]abi.RegisteredSealProof {
+	proofs := map[abi.SectorNumber]abi.RegisteredSealProof{}
+	for _, sector := range onChain {
+		proofs[sector.SectorNumber] = sector.SealProof
+	}
+	return proofs
+}
+
+// sectorDeals returns the number of deals in the sector, pieces without a deal have a zero deal ID
+func sectorDeals(detail api.SectorInfo) int {
+	deals := 0
+	for _, deal := range detail.Deals {
+		if deal != 0 {
+			deals++
+		}
+	}
+	return deals
+}
+
+// sectorPledged tells if the sector was started as a committed capacity sector
+func sectorPledged(detail api.SectorInfo) bool {
+	return len(detail.Log) > 0 && detail.Log[0].Kind == "event;sealing.SectorStartCC"
+}
+
+// finalizedTimes returns the time every sector was finalized at
+func finalizedTimes(sectors []api.SectorInfo) []int64 {
+	var times []int64
+	for _, detail := range sectors {
+		for _, entry := range detail.Log {
+			if entry.Kind == "event;sealing.SectorFinalized" {
+				times = append(times, int64(entry.Timestamp))
+			}
+		}
+	}
+	return times
+}
+
+// generateSectorSummary 按状态, 类型和证明类型汇总扇区数量.
+// 还没有上链的扇区使用新扇区的证明类型
+// generateSectorSummary counts the sectors by state, type (pledged or deal) and proof type. Sectors not
+// on chain yet get the proof type new sectors are sealed with.
+func generateSectorSummary(minerId address.Address, minerHost string, sectors []api.SectorInfo, proofs map[abi.SectorNumber]abi.RegisteredSealProof, newProof abi.RegisteredSealProof) {
+	type summaryKey struct {
+		state      api.SectorState
+		sectorType string
+		proof      abi.RegisteredSealProof
+	}
+	summary := map[summaryKey]int{}
+	for _, detail := range sectors {
+		key := summaryKey{state: detail.State, sectorType: "deal", proof: newProof}
+		if sectorPledged(detail) {
+			key.sectorType = "pledged"
+		}
+		if proof, ok := proofs[detail.SectorID]; ok {
+			key.proof = proof
+		}
+		summary[key]++
+	}
+	var keys []summaryKey
+	for key := range summary {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].state != keys[j].state {
+			return keys[i].state < keys[j].state
+		}
+		if keys[i].sectorType != keys[j].sectorType {
+			return keys[i].sectorType < keys[j].sectorType
+		}
+		return keys[i].proof < keys[j].proof
+	})
+
+	fmt.Println("# HELP lotus_miner_sector_state_total number of sectors by state, type and proof type")
+	fmt.Println("# TYPE lotus_miner_sector_state_total gauge")
+	for _, key := range keys {
+		fmt.Print("lotus_miner_sector_state_total { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", state=", `"`, key.state, `"`, ", type=", `"`, key.sectorType, `"`, ", proof_type=", `"`, sealProofName(key.proof), `"`, " } ", summary[key], "\n")
+	}
+}
+
+// generateSectors 输出每个扇区的状态 (SECTOR_DETAILS 开启时), 未证明扇区的事件时间
+// generateSectors prints the state of every sector when SECTOR_DETAILS is set, and the events of the
+// sectors that are not proven yet
+func generateSectors(minerId address.Address, minerHost string, sectors []api.SectorInfo) {
+	fmt.Println("# HELP lotus_miner_sector_state sector state")
+	fmt.Println("# TYPE lotus_miner_sector_state gauge")
+	fmt.Println("# HELP lotus_miner_sector_event contains important event of the sector life, for sectors not proven yet")
+	fmt.Println("# TYPE lotus_miner_sector_event gauge")
+	for _, detail := range sectors {
+		sector := detail.SectorID
+		if Config.SectorDetails {
+			pledged := 0
+			if sectorPledged(detail) {
+				pledged = 1
+			}
+			fmt.Print("lotus_miner_sector_state { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", sector_id=", `"`, sector, `"`, ", state=", `"`, detail.State, `"`, ", pledged=", `"`, pledged, `"`, ", deals=", `"`, sectorDeals(detail), `"`, ", verified_weight=", `"`, detail.VerifiedDealWeight, `"`, " } 1\n")
+		}
+		if !provenStates[detail.State] && len(detail.Log) > 0 {
+			generateSectorEvents(minerId, minerHost, detail)
+		}
+	}
+}
+
+// generateSectorEvents prints the creation, packed and finalized times of a sector
+func generateSectorEvents(minerId address.Address, minerHost string, detail api.SectorInfo) {
+	sector := detail.SectorID
+	creationDate := detail.Log[0].Timestamp
+	packedDate := ""
+	finalizedDate := ""
+	for i := 0; i < len(detail.Log); i++ {
+		if detail.Log[i].Kind == "event;sealing.SectorPacked" {
+			packedDate = strconv.Itoa(int(detail.Log[i].Timestamp))
+		}
+		if detail.Log[i].Kind == "event;sealing.SectorFinalized" {
+			finalizedDate = strconv.Itoa(int(detail.Log[i].Timestamp))
+		}
+	}
+	if packedDate != "" {
+		fmt.Print("lotus_miner_sector_event { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", sector_id=", `"`, sector, `", event_type="packed" } `, packedDate, "\n")
+	}
+	if creationDate != 0 {
+		fmt.Print("lotus_miner_sector_event { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", sector_id=", `"`, sector, `", event_type="creation" } `, creationDate, "\n")
+	}
+	if finalizedDate != "" {
+		fmt.Print("lotus_miner_sector_event { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", sector_id=", `"`, sector, `", event_type="finalized" } `, finalizedDate, "\n")
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+)
+
+// serveCmd 启动详情 HTTP 服务, 数据来自导出器保存的状态文件
+// serveCmd starts the details HTTP server, it answers from the state file saved by the exporter runs
+func serveCmd(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	listen := fs.String("listen", Config.ListenAddress, "address the details server listens on")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	http.HandleFunc("/details/deadlines", deadlinesHandler)
+	http.HandleFunc("/details/failures", failuresHandler)
+	http.HandleFunc("/details/sector-locations", sectorLocationsHandler)
+	http.HandleFunc("/details/stuck-sectors", stuckSectorsHandler)
+	fmt.Println("farcaster details server listening on", *listen)
+	return http.ListenAndServe(*listen, nil)
+}
+
+// writeJSON 以 JSON 格式返回结果
+// writeJSON writes v as the JSON body of the response
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fmt.Println("writeJSON error", err)
+	}
+}
+
+// failuresHandler returns the failed message counters, the first one holds the last failure
+func failuresHandler(w http.ResponseWriter, r *http.Request) {
+	state, err := loadState(Config.StatePath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	failures := sortedFailures(state)
+	var last *MessageFailures
+	if len(failures) > 0 {
+		last = failures[0]
+	}
+	writeJSON(w, struct {
+		Last     *MessageFailures
+		Failures []*MessageFailures
+	}{last, failures})
+}
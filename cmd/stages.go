@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+	"github.com/google/uuid"
+)
+
+// sealingStage 是封装流程中的一个阶段, 从 start 中最后一个事件开始, 到 end 事件结束
+// sealingStage is one stage of the sealing pipeline. It starts with the last of the start events (the
+// first log entry when there is none) and ends with the end event.
+type sealingStage struct {
+	Name  string
+	Start []string
+	End   string
+	// Task is the worker task doing the stage, empty for the stages waiting on chain
+	Task sealtasks.TaskType
+}
+
+var sealingStages = []sealingStage{
+	{"Packing", nil, "event;sealing.SectorPacked", sealtasks.TTAddPiece},
+	{"PreCommit1", []string{"event;sealing.SectorTicket", "event;sealing.SectorRetrySealPreCommit1"}, "event;sealing.SectorPreCommit1", sealtasks.TTPreCommit1},
+	{"PreCommit2", []string{"event;sealing.SectorPreCommit1", "event;sealing.SectorRetrySealPreCommit2"}, "event;sealing.SectorPreCommit2", sealtasks.TTPreCommit2},
+	{"WaitSeed", []string{"event;sealing.SectorPreCommitLanded", "event;sealing.SectorRetryWaitSeed"}, "event;sealing.SectorSeedReady", ""},
+	{"Committing", []string{"event;sealing.SectorSeedReady", "event;sealing.SectorRetryComputeProof", "event;sealing.SectorRetryInvalidProof"}, "event;sealing.SectorCommitted", sealtasks.TTCommit2},
+	{"CommitWait", []string{"event;sealing.SectorCommitSubmitted", "event;sealing.SectorRetryCommitWait"}, "event;sealing.SectorProving", ""},
+	{"FinalizeSector", []string{"event;sealing.SectorProving", "event;sealing.SectorRetryFinalize"}, "event;sealing.SectorFinalized", sealtasks.TTFinalize},
+}
+
+var summaryQuantiles = []float64{0.5, 0.9, 0.99}
+
+// stageEnd returns when the stage started and ended for the sector, ok is false when it didn't end
+func stageEnd(stage sealingStage, log []api.SectorLog) (start uint64, end uint64, ok bool) {
+	if len(log) == 0 {
+		return 0, 0, false
+	}
+	start = log[0].Timestamp
+	for _, entry := range log {
+		for _, kind := range stage.Start {
+			if entry.Kind == kind {
+				start = entry.Timestamp
+			}
+		}
+		if entry.Kind == stage.End {
+			end = entry.Timestamp
+			ok = true
+			break
+		}
+	}
+	return start, end, ok
+}
+
+// sectorWorkerKey is the key of the state entry remembering which worker ran a task of a sector
+func sectorWorkerKey(sector uint64, task sealtasks.TaskType) string {
+	return strconv.FormatUint(sector, 10) + ";" + string(task)
+}
+
+// recordSectorWorkers 记录每个扇区的任务由哪个 worker 执行
+// recordSectorWorkers remembers which worker host runs the tasks of every sector, the jobs are only
+// visible while they run so the mapping is kept in the state until the sector is sealed
+func recordSectorWorkers(state *State, workerJobs map[uuid.UUID][]storiface.WorkerJob, workerStats map[uuid.UUID]storiface.WorkerStats, sectors []api.SectorInfo, now int64) {
+	for wrk, jobList := range workerJobs {
+		workerHost := workerStats[wrk].Info.Hostname
+		if workerHost == "" {
+			continue
+		}
+		for _, job := range jobList {
+			state.SectorWorkers[sectorWorkerKey(uint64(job.Sector.Number), job.Task)] = workerHost
+		}
+	}
+
+	// 扇区封装完成并超出统计窗口后丢弃
+	// forget the sectors once they are sealed and out of the statistics window
+	keep := map[string]bool{}
+	window := int64(Config.SealingStatsWindowDays * secondsPerDay)
+	for _, detail := range sectors {
+		if provenStates[detail.State] && len(detail.Log) > 0 && now-int64(detail.Log[len(detail.Log)-1].Timestamp) > window {
+			continue
+		}
+		for _, stage := range sealingStages {
+			keep[sectorWorkerKey(uint64(detail.SectorID), stage.Task)] = true
+		}
+	}
+	for key := range state.SectorWorkers {
+		if !keep[key] {
+			delete(state.SectorWorkers, key)
+		}
+	}
+}
+
+// printSummary prints the quantiles, sum and count of durations as a prometheus summary
+func printSummary(name string, labels string, durations []float64) {
+	sort.Float64s(durations)
+	sum := 0.0
+	for _, d := range durations {
+		sum += d
+	}
+	for _, q := range summaryQuantiles {
+		value := math.NaN()
+		if len(durations) > 0 {
+			value = durations[int(math.Ceil(q*float64(len(durations))))-1]
+		}
+		fmt.Print(name, " { ", labels, ", quantile=", `"`, q, `"`, " } ", value, "\n")
+	}
+	fmt.Print(name, "_sum { ", labels, " } ", sum, "\n")
+	fmt.Print(name, "_count { ", labels, " } ", len(durations), "\n")
+}
+
+// generateSealingStages 输出每个封装阶段的耗时, 只统计在 SEALING_STATS_WINDOW_DAYS 内完成的阶段
+// generateSealingStages prints the duration of every sealing stage that ended during the last
+// SEALING_STATS_WINDOW_DAYS days, by stage and by worker host when the worker is known
+func generateSealingStages(minerId address.Address, minerHost string, sectors []api.SectorInfo, state *State, now int64) {
+	window := int64(Config.SealingStatsWindowDays * secondsPerDay)
+	byStage := map[string][]float64{}
+	byWorker := map[string]map[string][]float64{}
+	for _, detail := range sectors {
+		for _, stage := range sealingStages {
+			start, end, ok := stageEnd(stage, detail.Log)
+			if !ok || end < start || now-int64(end) > window {
+				continue
+			}
+			duration := float64(end - start)
+			byStage[stage.Name] = append(byStage[stage.Name], duration)
+			if stage.Task == "" {
+				continue
+			}
+			if host, ok := state.SectorWorkers[sectorWorkerKey(uint64(detail.SectorID), stage.Task)]; ok {
+				if byWorker[stage.Name] == nil {
+					byWorker[stage.Name] = map[string][]float64{}
+				}
+				byWorker[stage.Name][host] = append(byWorker[stage.Name][host], duration)
+			}
+		}
+	}
+
+	fmt.Println("# HELP lotus_miner_sealing_stage_duration_seconds duration of the sealing stages that ended during the statistics window")
+	fmt.Println("# TYPE lotus_miner_sealing_stage_duration_seconds summary")
+	for _, stage := range sealingStages {
+		labels := fmt.Sprint("miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", stage=", `"`, stage.Name, `"`)
+		printSummary("lotus_miner_sealing_stage_duration_seconds", labels, byStage[stage.Name])
+	}
+
+	fmt.Println("# HELP lotus_miner_sealing_stage_worker_duration_seconds duration of the sealing stages that ended during the statistics window by worker host")
+	fmt.Println("# TYPE lotus_miner_sealing_stage_worker_duration_seconds summary")
+	for _, stage := range sealingStages {
+		var hosts []string
+		for host := range byWorker[stage.Name] {
+			hosts = append(hosts, host)
+		}
+		sort.Strings(hosts)
+		for _, host := range hosts {
+			labels := fmt.Sprint("miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", stage=", `"`, stage.Name, `"`, ", worker_host=", `"`, host, `"`)
+			printSummary("lotus_miner_sealing_stage_worker_duration_seconds", labels, byWorker[stage.Name][host])
+		}
+	}
+}
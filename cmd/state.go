@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// State 保存两次运行之间需要保留的数据
+// State holds what farcaster has to remember between two runs of the exporter
+type State struct {
+	// MpoolFirstSeen maps a pending message CID to the unix time it was first seen in the mpool
+	MpoolFirstSeen map[string]int64 `json:"mpool_first_seen"`
+	// ChainScanHeight is the height of the last tipset whose messages were scanned
+	ChainScanHeight int64 `json:"chain_scan_height"`
+	// GasSpent is the gas spent by role and method, keyed by "role;method"
+	GasSpent map[string]*GasSpent `json:"gas_spent"`
+	// MessageFailures counts the failed messages, keyed by "role;method;exit code"
+	MessageFailures map[string]*MessageFailures `json:"message_failures"`
+	// SectorWorkers maps "sector;task" to the host of the worker that ran the task
+	SectorWorkers map[string]string `json:"sector_workers"`
+	// SectorFailures counts the sealing failures, keyed by "kind;worker host"
+	SectorFailures map[string]*SectorFailures `json:"sector_failures"`
+	// SectorFailuresSeen is the number of failures already counted in the log of each sector
+	SectorFailuresSeen map[string]int `json:"sector_failures_seen"`
+	// SectorFailuresBaseline is set once the failures already in the sector logs have been recorded
+	SectorFailuresBaseline bool `json:"sector_failures_baseline"`
+	// WPoStCheckedClose is the close epoch of the last deadline checked for missed WindowPoSts
+	WPoStCheckedClose int64 `json:"wpost_checked_close"`
+	// WPoStMissed counts the missed WindowPoSts, keyed by deadline index
+	WPoStMissed map[string]*WPoStMissed `json:"wpost_missed"`
+	// WPoStSubmissions sums the SubmitWindowedPoSt messages, keyed by deadline index
+	WPoStSubmissions map[string]*WPoStSubmissions `json:"wpost_submissions"`
+	// WPoStLatency is the histogram of the submission latency
+	WPoStLatency WPoStLatency `json:"wpost_latency"`
+	// FaultsHeight is the head height of the last fault check, zero before the first one
+	FaultsHeight int64 `json:"faults_height"`
+	// FaultySince maps a faulty sector to the epoch it was first seen faulty
+	FaultySince map[string]int64 `json:"faulty_since"`
+	// FaultTermination maps a faulty sector to the epoch the chain terminates it if it doesn't recover
+	FaultTermination map[string]int64 `json:"fault_termination"`
+	// FaultRecovering holds the sectors that were recovering at the last fault check
+	FaultRecovering map[string]bool `json:"fault_recovering"`
+	// Faults are the fault and recovery counters
+	Faults FaultCounters `json:"faults"`
+}
+
+func newState() *State {
+	return &State{
+		MpoolFirstSeen:     map[string]int64{},
+		GasSpent:           map[string]*GasSpent{},
+		MessageFailures:    map[string]*MessageFailures{},
+		SectorWorkers:      map[string]string{},
+		SectorFailures:     map[string]*SectorFailures{},
+		SectorFailuresSeen: map[string]int{},
+		WPoStMissed:        map[string]*WPoStMissed{},
+		WPoStSubmissions:   map[string]*WPoStSubmissions{},
+		FaultySince:        map[string]int64{},
+		FaultTermination:   map[string]int64{},
+		FaultRecovering:    map[string]bool{},
+	}
+}
+
+// errStateCorrupt 表示状态文件存在但无法解析
+// errStateCorrupt is returned by loadState when the state file exists but can't be decoded
+var errStateCorrupt = errors.New("state file is corrupt")
+
+// loadState reads the state file, a missing file gives an empty state. It only reads the file, a file
+// that can't be decoded returns errStateCorrupt.
+func loadState(path string) (*State, error) {
+	state := newState()
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return newState(), fmt.Errorf("%w: %v", errStateCorrupt, err)
+	}
+	if state.MpoolFirstSeen == nil {
+		state.MpoolFirstSeen = map[string]int64{}
+	}
+	if state.GasSpent == nil {
+		state.GasSpent = map[string]*GasSpent{}
+	}
+	if state.MessageFailures == nil {
+		state.MessageFailures = map[string]*MessageFailures{}
+	}
+	if state.SectorWorkers == nil {
+		state.SectorWorkers = map[string]string{}
+	}
+	if state.SectorFailures == nil {
+		state.SectorFailures = map[string]*SectorFailures{}
+	}
+	if state.SectorFailuresSeen == nil {
+		state.SectorFailuresSeen = map[string]int{}
+	}
+	if state.WPoStMissed == nil {
+		state.WPoStMissed = map[string]*WPoStMissed{}
+	}
+	if state.WPoStSubmissions == nil {
+		state.WPoStSubmissions = map[string]*WPoStSubmissions{}
+	}
+	if state.FaultySince == nil {
+		state.FaultySince = map[string]int64{}
+	}
+	if state.FaultTermination == nil {
+		state.FaultTermination = map[string]int64{}
+	}
+	if state.FaultRecovering == nil {
+		state.FaultRecovering = map[string]bool{}
+	}
+	return state, nil
+}
+
+// moveStateAside renames a corrupt state file with a .corrupt suffix, the next run starts with an
+// empty state
+func moveStateAside(path string) error {
+	corrupt := path + ".corrupt"
+	if err := os.Rename(path, corrupt); err != nil {
+		return err
+	}
+	fmt.Println("state file moved to", corrupt)
+	return nil
+}
+
+// saveState writes the state file atomically
+func saveState(path string, state *State) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
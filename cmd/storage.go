@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/lotus/extern/sector-storage/fsutil"
+	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// storagePath 是 sector index 中的一个存储路径
+// storagePath is one storage path of the sector index with its usage
+type storagePath struct {
+	Info stores.StorageInfo
+	// LocalPath is set when the path is attached to the miner itself
+	LocalPath string
+	Stat      fsutil.FsStat
+	// StatErr is set when the path couldn't be reached, Stat is empty then
+	StatErr error
+	// Sectors counts the sector files declared in the path by file type
+	Sectors map[storiface.SectorFileType]int
+}
+
+// Used returns the bytes used on the path, the same way "lotus-miner storage list" computes it
+func (p *storagePath) Used() int64 {
+	if p.Stat.Max > 0 {
+		return p.Stat.Used
+	}
+	return p.Stat.Capacity - p.Stat.FSAvailable
+}
+
+// listStoragePaths 读取 sector index 中的全部存储路径
+// listStoragePaths returns every storage path of the sector index, sorted by ID
+func listStoragePaths(ctx context.Context) ([]*storagePath, error) {
+	decls, err := storageMiner.StorageList(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("StorageList: %w", err)
+	}
+	local, err := storageMiner.StorageLocal(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("StorageLocal: %w", err)
+	}
+
+	var paths []*storagePath
+	for id, sectors := range decls {
+		info, err := storageMiner.StorageInfo(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("StorageInfo %s: %w", id, err)
+		}
+		path := &storagePath{
+			Info:      info,
+			LocalPath: local[id],
+			Sectors:   map[storiface.SectorFileType]int{},
+		}
+		path.Stat, path.StatErr = storageMiner.StorageStat(ctx, id)
+		for _, decl := range sectors {
+			for _, fileType := range storiface.PathTypes {
+				if decl.SectorFileType&fileType != 0 {
+					path.Sectors[fileType]++
+				}
+			}
+		}
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool { return paths[i].Info.ID < paths[j].Info.ID })
+	return paths, nil
+}
+
+// generateStorage 输出每个存储路径的容量和扇区文件数
+// generateStorage prints the capacity and usage of every storage path with the number of sector files it holds
+func generateStorage(minerId address.Address, minerHost string, paths []*storagePath) {
+	fmt.Println("# HELP lotus_miner_storage_info storage path information, value is set to 1 when the path could be reached")
+	fmt.Println("# TYPE lotus_miner_storage_info gauge")
+	fmt.Println("# HELP lotus_miner_storage_capacity_bytes capacity of the storage path")
+	fmt.Println("# TYPE lotus_miner_storage_capacity_bytes gauge")
+	fmt.Println("# HELP lotus_miner_storage_available_bytes space available for sectors on the storage path")
+	fmt.Println("# TYPE lotus_miner_storage_available_bytes gauge")
+	fmt.Println("# HELP lotus_miner_storage_reserved_bytes space reserved for sectors being written on the storage path")
+	fmt.Println("# TYPE lotus_miner_storage_reserved_bytes gauge")
+	fmt.Println("# HELP lotus_miner_storage_used_bytes space used on the storage path")
+	fmt.Println("# TYPE lotus_miner_storage_used_bytes gauge")
+	fmt.Println("# HELP lotus_miner_storage_sector_files number of sector files on the storage path by file type")
+	fmt.Println("# TYPE lotus_miner_storage_sector_files gauge")
+	for _, path := range paths {
+		id := path.Info.ID
+		up := 1
+		if path.StatErr != nil {
+			up = 0
+		}
+		fmt.Print("lotus_miner_storage_info { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", storage_id=", `"`, id, `"`, ", path=", `"`, path.LocalPath, `"`, ", can_seal=", `"`, path.Info.CanSeal, `"`, ", can_store=", `"`, path.Info.CanStore, `"`, ", weight=", `"`, path.Info.Weight, `"`, ", urls=", `"`, strings.Join(path.Info.URLs, ","), `"`, " } ", up, "\n")
+		if path.StatErr == nil {
+			fmt.Print("lotus_miner_storage_capacity_bytes { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", storage_id=", `"`, id, `"`, " } ", path.Stat.Capacity, "\n")
+			fmt.Print("lotus_miner_storage_available_bytes { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", storage_id=", `"`, id, `"`, " } ", path.Stat.Available, "\n")
+			fmt.Print("lotus_miner_storage_reserved_bytes { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", storage_id=", `"`, id, `"`, " } ", path.Stat.Reserved, "\n")
+			fmt.Print("lotus_miner_storage_used_bytes { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", storage_id=", `"`, id, `"`, " } ", path.Used(), "\n")
+		}
+		for _, fileType := range storiface.PathTypes {
+			fmt.Print("lotus_miner_storage_sector_files { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", storage_id=", `"`, id, `"`, ", file_type=", `"`, fileType, `"`, " } ", path.Sectors[fileType], "\n")
+		}
+	}
+}
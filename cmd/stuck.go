@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/api"
+)
+
+// stuckSector 是在某个状态停留超过阈值的扇区
+// stuckSector is a sector that stayed in its state longer than the threshold of the state
+type stuckSector struct {
+	Sector abi.SectorNumber
+	State  api.SectorState
+	// Since is the time of the last log entry, Duration the seconds elapsed since then
+	Since       int64
+	Duration    int64
+	LastKind    string
+	LastMessage string
+}
+
+// stuckThreshold returns the threshold of a state, ok is false when the state has none
+func stuckThreshold(state api.SectorState) (time.Duration, bool) {
+	threshold, ok := Config.SectorStuckThresholds[string(state)]
+	if !ok {
+		return 0, false
+	}
+	d, err := time.ParseDuration(threshold)
+	if err != nil {
+		fmt.Println("SECTOR_STUCK_THRESHOLDS error", state, err)
+		return 0, false
+	}
+	return d, true
+}
+
+// loadStuckCandidates 只获取处于有阈值的状态的扇区, 避免读取全部扇区
+// loadStuckCandidates fetches the status of the sectors in a state of SECTOR_STUCK_THRESHOLDS only, the
+// other sectors can't be stuck and are not read
+func loadStuckCandidates(ctx context.Context) ([]api.SectorInfo, error) {
+	var states []api.SectorState
+	for state := range Config.SectorStuckThresholds {
+		states = append(states, api.SectorState(state))
+	}
+	if len(states) == 0 {
+		return nil, nil
+	}
+	numbers, err := storageMiner.SectorsListInStates(ctx, states)
+	if err != nil {
+		return nil, fmt.Errorf("SectorsListInStates: %w", err)
+	}
+	sectors, err := fetchSectors(ctx, numbers)
+	if err != nil {
+		return nil, err
+	}
+	return sortedSectors(sectors), nil
+}
+
+// findStuckSectors 找出停留时间超过阈值的扇区, 最久的在前
+// findStuckSectors returns the sectors whose last log entry is older than the threshold of their
+// state, the oldest first
+func findStuckSectors(sectors []api.SectorInfo, now int64) []stuckSector {
+	var stuck []stuckSector
+	for _, detail := range sectors {
+		threshold, ok := stuckThreshold(detail.State)
+		if !ok || len(detail.Log) == 0 {
+			continue
+		}
+		last := detail.Log[len(detail.Log)-1]
+		duration := now - int64(last.Timestamp)
+		if duration <= int64(threshold.Seconds()) {
+			continue
+		}
+		stuck = append(stuck, stuckSector{
+			Sector:      detail.SectorID,
+			State:       detail.State,
+			Since:       int64(last.Timestamp),
+			Duration:    duration,
+			LastKind:    last.Kind,
+			LastMessage: last.Message,
+		})
+	}
+	sort.Slice(stuck, func(i, j int) bool { return stuck[i].Duration > stuck[j].Duration })
+	return stuck
+}
+
+// generateStuckSectors 输出每个状态卡住的扇区数和最久的停留时间
+// generateStuckSectors prints the number of stuck sectors and the oldest one for every state with a threshold
+func generateStuckSectors(minerId address.Address, minerHost string, sectors []api.SectorInfo, now int64) {
+	count := map[api.SectorState]int{}
+	oldest := map[api.SectorState]int64{}
+	for _, s := range findStuckSectors(sectors, now) {
+		count[s.State]++
+		if s.Duration > oldest[s.State] {
+			oldest[s.State] = s.Duration
+		}
+	}
+	var states []string
+	for state := range Config.SectorStuckThresholds {
+		states = append(states, state)
+	}
+	sort.Strings(states)
+
+	fmt.Println("# HELP lotus_miner_sector_stuck number of sectors in the state for longer than its threshold")
+	fmt.Println("# TYPE lotus_miner_sector_stuck gauge")
+	fmt.Println("# HELP lotus_miner_sector_stuck_oldest_seconds time the oldest stuck sector of the state has been waiting")
+	fmt.Println("# TYPE lotus_miner_sector_stuck_oldest_seconds gauge")
+	for _, state := range states {
+		threshold, ok := stuckThreshold(api.SectorState(state))
+		if !ok {
+			continue
+		}
+		fmt.Print("lotus_miner_sector_stuck { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", state=", `"`, state, `"`, ", threshold=", `"`, threshold, `"`, " } ", count[api.SectorState(state)], "\n")
+		fmt.Print("lotus_miner_sector_stuck_oldest_seconds { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", state=", `"`, state, `"`, " } ", oldest[api.SectorState(state)], "\n")
+	}
+}
+
+// stuckSectorsHandler 返回最久的卡住扇区, 参数 limit 默认 20
+// stuckSectorsHandler returns the oldest stuck sectors, "limit" of them (20 by default)
+func stuckSectorsHandler(w http.ResponseWriter, r *http.Request) {
+	limit := 20
+	if l := r.URL.Query().Get("limit"); l != "" {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil {
+			http.Error(w, "invalid limit "+l, http.StatusBadRequest)
+			return
+		}
+	}
+	sectors, err := loadStuckCandidates(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	stuck := findStuckSectors(sectors, time.Now().Unix())
+	if len(stuck) > limit {
+		stuck = stuck[:limit]
+	}
+	writeJSON(w, stuck)
+}
+
+// stuckCmd 列出最久的卡住扇区
+// stuckCmd lists the oldest stuck sectors with their last log entry
+func stuckCmd(args []string) error {
+	fs := flag.NewFlagSet("stuck", flag.ContinueOnError)
+	limit := fs.Int("limit", 20, "number of sectors to list")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	sectors, err := loadStuckCandidates(context.Background())
+	if err != nil {
+		return err
+	}
+	stuck := findStuckSectors(sectors, time.Now().Unix())
+	if len(stuck) > *limit {
+		stuck = stuck[:*limit]
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SECTOR\tSTATE\tSINCE\tFOR\tLAST EVENT\tMESSAGE")
+	for _, s := range stuck {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", s.Sector, s.State, time.Unix(s.Since, 0).Format(time.RFC3339), time.Duration(s.Duration)*time.Second, s.LastKind, s.LastMessage)
+	}
+	return tw.Flush()
+}
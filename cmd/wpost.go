@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-bitfield"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// WPoStMissed 是某个 deadline 漏掉的 WindowPoSt
+// WPoStMissed counts the WindowPoSt partitions missed in one deadline and the faults they caused
+type WPoStMissed struct {
+	Deadline   uint64
+	Partitions int64
+	// Faults is the number of sectors that became faulty, FailedRecoveries the number of recovering
+	// sectors that stayed faulty
+	Faults           int64
+	FailedRecoveries int64
+	// LastClose is the close epoch of the last deadline with a missed partition
+	LastClose int64
+}
+
+// missedPartition 比较 deadline 关闭前后的 partition, 关闭前没有提交证明且健康扇区在关闭后变成错误时才算漏掉
+// missedPartition compares a partition before and after its deadline closed. The partition missed its
+// WindowPoSt when it was not in PostSubmissions before the close and some of its healthy sectors were
+// faulty after it: a proof landing in the last epoch of the deadline is only visible after the close.
+// It also returns the number of sectors that became faulty and of recoveries that failed.
+func missedPartition(before, after api.Partition, index uint64, submissions bitfield.BitField) (bool, uint64, uint64, error) {
+	proven, err := submissions.IsSet(index)
+	if err != nil || proven {
+		return false, 0, 0, err
+	}
+	healthy, err := bitfield.SubtractBitField(before.LiveSectors, before.FaultySectors)
+	if err != nil {
+		return false, 0, 0, err
+	}
+	faulted, err := bitfield.IntersectBitField(healthy, after.FaultySectors)
+	if err != nil {
+		return false, 0, 0, err
+	}
+	faults, err := faulted.Count()
+	if err != nil {
+		return false, 0, 0, err
+	}
+	stillFaulty, err := bitfield.SubtractBitField(after.FaultySectors, after.RecoveringSectors)
+	if err != nil {
+		return false, 0, 0, err
+	}
+	failed, err := bitfield.IntersectBitField(before.RecoveringSectors, stillFaulty)
+	if err != nil {
+		return false, 0, 0, err
+	}
+	recoveries, err := failed.Count()
+	if err != nil {
+		return false, 0, 0, err
+	}
+	return faults+recoveries > 0, faults, recoveries, nil
+}
+
+// checkDeadlineClose 比较 deadline 关闭前后的状态, 记录漏掉的 partition
+// checkDeadlineClose compares the deadline state in the last tipset before it closed with the state in
+// the first tipset after it, and records the partitions that missed their WindowPoSt
+func checkDeadlineClose(ctx context.Context, minerId address.Address, head *types.TipSet, index uint64, closeEpoch abi.ChainEpoch, state *State) error {
+	before, err := fullNode.ChainGetTipSetByHeight(ctx, closeEpoch-1, head.Key())
+	if err != nil {
+		return fmt.Errorf("ChainGetTipSetByHeight %d: %w", closeEpoch-1, err)
+	}
+	// 空块高度返回之前的 tipset, 找到关闭后的第一个 tipset
+	// a null round gives the previous tipset, look for the first one at or after the close
+	var after *types.TipSet
+	for h := closeEpoch; h <= head.Height(); h++ {
+		ts, err := fullNode.ChainGetTipSetByHeight(ctx, h, head.Key())
+		if err != nil {
+			return fmt.Errorf("ChainGetTipSetByHeight %d: %w", h, err)
+		}
+		if ts.Height() >= closeEpoch {
+			after = ts
+			break
+		}
+	}
+	if after == nil {
+		return fmt.Errorf("no tipset after the close at %d", closeEpoch)
+	}
+
+	deadlines, err := fullNode.StateMinerDeadlines(ctx, minerId, before.Key())
+	if err != nil {
+		return fmt.Errorf("StateMinerDeadlines: %w", err)
+	}
+	if index >= uint64(len(deadlines)) {
+		return nil
+	}
+	partitions, err := fullNode.StateMinerPartitions(ctx, minerId, index, before.Key())
+	if err != nil {
+		return fmt.Errorf("StateMinerPartitions %d: %w", index, err)
+	}
+	partitionsAfter, err := fullNode.StateMinerPartitions(ctx, minerId, index, after.Key())
+	if err != nil {
+		return fmt.Errorf("StateMinerPartitions %d: %w", index, err)
+	}
+	for partIdx, partition := range partitions {
+		if partIdx >= len(partitionsAfter) {
+			break
+		}
+		missed, faults, recoveries, err := missedPartition(partition, partitionsAfter[partIdx], uint64(partIdx), deadlines[index].PostSubmissions)
+		if err != nil {
+			return err
+		}
+		if !missed {
+			continue
+		}
+		key := strconv.FormatUint(index, 10)
+		m, ok := state.WPoStMissed[key]
+		if !ok {
+			m = &WPoStMissed{Deadline: index}
+			state.WPoStMissed[key] = m
+		}
+		m.Partitions++
+		m.Faults += int64(faults)
+		m.FailedRecoveries += int64(recoveries)
+		m.LastClose = int64(closeEpoch)
+	}
+	return nil
+}
+
+// trackMissedPoSts 检查上次运行之后关闭的 deadline, 每次最多检查一个证明周期
+// trackMissedPoSts checks every deadline closed since state.WPoStCheckedClose, at most one proving
+// period per run. A deadline is only checked once its close has messageConfidence confirmations, and
+// a first run starts with the last deadline closed.
+func trackMissedPoSts(ctx context.Context, minerId address.Address, head *types.TipSet, state *State) error {
+	di, err := fullNode.StateMinerProvingDeadline(ctx, minerId, head.Key())
+	if err != nil {
+		return fmt.Errorf("StateMinerProvingDeadline: %w", err)
+	}
+	window := di.WPoStChallengeWindow
+	// 当前 deadline 打开时上一个 deadline 关闭
+	// the previous deadline closed when the current one opened
+	latest := di.Open
+	if head.Height() < latest+messageConfidence {
+		latest -= window
+	}
+	checked := abi.ChainEpoch(state.WPoStCheckedClose)
+	if checked == 0 {
+		checked = latest - window
+	}
+	first := checked + window
+	if latest-first >= window*abi.ChainEpoch(di.WPoStPeriodDeadlines) {
+		latest = first + window*abi.ChainEpoch(di.WPoStPeriodDeadlines-1)
+	}
+	n := int64(di.WPoStPeriodDeadlines)
+	for closeEpoch := first; closeEpoch <= latest; closeEpoch += window {
+		back := int64((di.Open - closeEpoch) / window)
+		index := ((int64(di.Index)-1-back)%n + n) % n
+		if err := checkDeadlineClose(ctx, minerId, head, uint64(index), closeEpoch, state); err != nil {
+			return err
+		}
+		state.WPoStCheckedClose = int64(closeEpoch)
+	}
+	return nil
+}
+
+// generateMissedPoSts 输出漏掉的 WindowPoSt 累计数
+// generateMissedPoSts prints the cumulative missed WindowPoSt counters of every deadline
+func generateMissedPoSts(minerId address.Address, minerHost string, state *State) {
+	fmt.Println("# HELP lotus_miner_wpost_checked_epoch close epoch of the last deadline checked for missed WindowPoSts")
+	fmt.Println("# TYPE lotus_miner_wpost_checked_epoch gauge")
+	fmt.Print("lotus_miner_wpost_checked_epoch { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, " } ", state.WPoStCheckedClose, "\n")
+
+	fmt.Println("# HELP lotus_miner_wpost_missed_partitions_total number of partitions missing from PostSubmissions when their deadline closed")
+	fmt.Println("# TYPE lotus_miner_wpost_missed_partitions_total counter")
+	fmt.Println("# HELP lotus_miner_wpost_missed_faults_total number of sectors that became faulty because of a missed WindowPoSt")
+	fmt.Println("# TYPE lotus_miner_wpost_missed_faults_total counter")
+	fmt.Println("# HELP lotus_miner_wpost_missed_recoveries_total number of recovering sectors that stayed faulty because of a missed WindowPoSt")
+	fmt.Println("# TYPE lotus_miner_wpost_missed_recoveries_total counter")
+	fmt.Println("# HELP lotus_miner_wpost_missed_last_epoch close epoch of the last deadline with a missed partition")
+	fmt.Println("# TYPE lotus_miner_wpost_missed_last_epoch gauge")
+	var missed []*WPoStMissed
+	for _, m := range state.WPoStMissed {
+		missed = append(missed, m)
+	}
+	sort.Slice(missed, func(i, j int) bool { return missed[i].Deadline < missed[j].Deadline })
+	for _, m := range missed {
+		fmt.Print("lotus_miner_wpost_missed_partitions_total { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", deadline=", `"`, m.Deadline, `"`, " } ", m.Partitions, "\n")
+		fmt.Print("lotus_miner_wpost_missed_faults_total { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", deadline=", `"`, m.Deadline, `"`, " } ", m.Faults, "\n")
+		fmt.Print("lotus_miner_wpost_missed_recoveries_total { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", deadline=", `"`, m.Deadline, `"`, " } ", m.FailedRecoveries, "\n")
+		fmt.Print("lotus_miner_wpost_missed_last_epoch { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", deadline=", `"`, m.Deadline, `"`, " } ", m.LastClose, "\n")
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/filecoin-project/go-bitfield"
+	"github.com/filecoin-project/lotus/api"
+)
+
+func testPartition(live, faulty, recovering []uint64) api.Partition {
+	return api.Partition{
+		AllSectors:        bitfield.NewFromSet(live),
+		LiveSectors:       bitfield.NewFromSet(live),
+		ActiveSectors:     bitfield.NewFromSet(live),
+		FaultySectors:     bitfield.NewFromSet(faulty),
+		RecoveringSectors: bitfield.NewFromSet(recovering),
+	}
+}
+
+func TestMissedPartition(t *testing.T) {
+	live := []uint64{1, 2, 3, 4}
+	tests := []struct {
+		name       string
+		before     api.Partition
+		after      api.Partition
+		submitted  []uint64
+		missed     bool
+		faults     uint64
+		recoveries uint64
+	}{
+		{"proven", testPartition(live, nil, nil), testPartition(live, nil, nil), []uint64{0}, false, 0, 0},
+		{"proof in the last epoch", testPartition(live, nil, nil), testPartition(live, nil, nil), nil, false, 0, 0},
+		{"missed", testPartition(live, nil, nil), testPartition(live, live, nil), nil, true, 4, 0},
+		{"missed with recoveries", testPartition(live, []uint64{1, 2}, []uint64{1}), testPartition(live, live, nil), nil, true, 2, 1},
+		{"all faulty", testPartition(live, live, nil), testPartition(live, live, nil), nil, false, 0, 0},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			missed, faults, recoveries, err := missedPartition(test.before, test.after, 0, bitfield.NewFromSet(test.submitted))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := fmt.Sprint(missed, faults, recoveries)
+			if want := fmt.Sprint(test.missed, test.faults, test.recoveries); got != want {
+				t.Errorf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// wpostLatencyBuckets 是提交延迟 (占挑战窗口的比例) 直方图的上界
+// wpostLatencyBuckets are the upper bounds of the submission latency histogram, as a fraction of the
+// challenge window
+var wpostLatencyBuckets = []float64{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9, 1}
+
+// WPoStSubmissions 是某个 deadline 的 SubmitWindowedPoSt 消息统计
+// WPoStSubmissions sums the SubmitWindowedPoSt messages sent for one deadline
+type WPoStSubmissions struct {
+	Deadline   uint64
+	Messages   int64
+	Partitions int64
+	GasUsed    int64
+	// GasBurned is the base fee burn plus the over estimation burn
+	GasBurned types.BigInt
+	// LastHeight, LastPartitions and LastLatency describe the last successful submission, the latency
+	// is the number of epochs between the deadline opening and the message inclusion
+	LastHeight     int64
+	LastPartitions []uint64
+	LastLatency    int64
+}
+
+// WPoStLatency 是提交延迟的直方图
+// WPoStLatency is the histogram of the submission latency as a fraction of the challenge window,
+// Buckets holds the cumulative count of every wpostLatencyBuckets bound
+type WPoStLatency struct {
+	Buckets []int64
+	Count   int64
+	Sum     float64
+}
+
+// accountWPoSt 记录一条 SubmitWindowedPoSt 消息覆盖的 deadline 和 partition, 延迟和 gas
+// accountWPoSt records the deadline and the partitions covered by a SubmitWindowedPoSt message, its
+// latency since the deadline opened and its gas. Failed messages only count for the gas.
+func accountWPoSt(ctx context.Context, minerId address.Address, head *types.TipSet, state *State, msg *chainMessage) error {
+	if msg.Message.To != minerId || msg.Message.Method != miner.Methods.SubmitWindowedPoSt {
+		return nil
+	}
+	var params miner.SubmitWindowedPoStParams
+	if err := params.UnmarshalCBOR(bytes.NewReader(msg.Message.Params)); err != nil {
+		return fmt.Errorf("SubmitWindowedPoSt params %s: %w", msg.Cid, err)
+	}
+
+	key := strconv.FormatUint(params.Deadline, 10)
+	submissions, ok := state.WPoStSubmissions[key]
+	if !ok {
+		submissions = &WPoStSubmissions{Deadline: params.Deadline, GasBurned: types.NewInt(0)}
+		state.WPoStSubmissions[key] = submissions
+	}
+	baseFeeBurn, overEstimationBurn, _ := gasOutputs(msg.Message, msg.Receipt.GasUsed, msg.BaseFee)
+	submissions.Messages++
+	submissions.GasUsed += msg.Receipt.GasUsed
+	submissions.GasBurned = types.BigAdd(submissions.GasBurned, types.BigAdd(baseFeeBurn, overEstimationBurn))
+	if msg.Receipt.ExitCode != 0 {
+		return nil
+	}
+
+	ts, err := fullNode.ChainGetTipSetByHeight(ctx, msg.Height, head.Key())
+	if err != nil {
+		return fmt.Errorf("ChainGetTipSetByHeight %d: %w", msg.Height, err)
+	}
+	di, err := fullNode.StateMinerProvingDeadline(ctx, minerId, ts.Key())
+	if err != nil {
+		return fmt.Errorf("StateMinerProvingDeadline: %w", err)
+	}
+	opened := di.PeriodStart + abi.ChainEpoch(params.Deadline)*di.WPoStChallengeWindow
+	latency := msg.Height - opened
+
+	var partitions []uint64
+	for _, partition := range params.Partitions {
+		partitions = append(partitions, partition.Index)
+	}
+	submissions.Partitions += int64(len(partitions))
+	submissions.LastHeight = int64(msg.Height)
+	submissions.LastPartitions = partitions
+	submissions.LastLatency = int64(latency)
+
+	ratio := float64(latency) / float64(di.WPoStChallengeWindow)
+	if len(state.WPoStLatency.Buckets) != len(wpostLatencyBuckets) {
+		state.WPoStLatency = WPoStLatency{Buckets: make([]int64, len(wpostLatencyBuckets))}
+	}
+	for i, bound := range wpostLatencyBuckets {
+		if ratio <= bound {
+			state.WPoStLatency.Buckets[i]++
+		}
+	}
+	state.WPoStLatency.Count++
+	state.WPoStLatency.Sum += ratio
+	return nil
+}
+
+// generateWPoStSubmissions 输出 WindowPoSt 提交统计和延迟直方图
+// generateWPoStSubmissions prints the SubmitWindowedPoSt counters of every deadline and the latency histogram
+func generateWPoStSubmissions(minerId address.Address, minerHost string, state *State) {
+	fmt.Println("# HELP lotus_miner_wpost_submissions_total number of SubmitWindowedPoSt messages landed on chain by deadline")
+	fmt.Println("# TYPE lotus_miner_wpost_submissions_total counter")
+	fmt.Println("# HELP lotus_miner_wpost_submission_partitions_total number of partitions proven by successful SubmitWindowedPoSt messages")
+	fmt.Println("# TYPE lotus_miner_wpost_submission_partitions_total counter")
+	fmt.Println("# HELP lotus_miner_wpost_gas_used_total gas used by the SubmitWindowedPoSt messages")
+	fmt.Println("# TYPE lotus_miner_wpost_gas_used_total counter")
+	fmt.Println("# HELP lotus_miner_wpost_gas_burned_total FIL burned by the SubmitWindowedPoSt messages, base fee and over estimation")
+	fmt.Println("# TYPE lotus_miner_wpost_gas_burned_total counter")
+	fmt.Println("# HELP lotus_miner_wpost_submission_last_epoch inclusion epoch of the last successful submission, partitions lists the partitions it covered")
+	fmt.Println("# TYPE lotus_miner_wpost_submission_last_epoch gauge")
+	fmt.Println("# HELP lotus_miner_wpost_submission_last_latency_epochs epochs between the deadline opening and the inclusion of the last successful submission")
+	fmt.Println("# TYPE lotus_miner_wpost_submission_last_latency_epochs gauge")
+	var submissions []*WPoStSubmissions
+	for _, s := range state.WPoStSubmissions {
+		submissions = append(submissions, s)
+	}
+	sort.Slice(submissions, func(i, j int) bool { return submissions[i].Deadline < submissions[j].Deadline })
+	for _, s := range submissions {
+		fmt.Print("lotus_miner_wpost_submissions_total { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", deadline=", `"`, s.Deadline, `"`, " } ", s.Messages, "\n")
+		fmt.Print("lotus_miner_wpost_submission_partitions_total { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", deadline=", `"`, s.Deadline, `"`, " } ", s.Partitions, "\n")
+		fmt.Print("lotus_miner_wpost_gas_used_total { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", deadline=", `"`, s.Deadline, `"`, " } ", s.GasUsed, "\n")
+		fmt.Print("lotus_miner_wpost_gas_burned_total { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", deadline=", `"`, s.Deadline, `"`, " } ", toFIL(s.GasBurned), "\n")
+		if s.LastHeight == 0 {
+			continue
+		}
+		var partitions []string
+		for _, p := range s.LastPartitions {
+			partitions = append(partitions, strconv.FormatUint(p, 10))
+		}
+		fmt.Print("lotus_miner_wpost_submission_last_epoch { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", deadline=", `"`, s.Deadline, `"`, ", partitions=", `"`, strings.Join(partitions, ","), `"`, " } ", s.LastHeight, "\n")
+		fmt.Print("lotus_miner_wpost_submission_last_latency_epochs { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", deadline=", `"`, s.Deadline, `"`, " } ", s.LastLatency, "\n")
+	}
+
+	latency := state.WPoStLatency
+	fmt.Println("# HELP lotus_miner_wpost_submission_latency_ratio latency of the successful submissions as a fraction of the challenge window")
+	fmt.Println("# TYPE lotus_miner_wpost_submission_latency_ratio histogram")
+	for i, bound := range wpostLatencyBuckets {
+		var count int64
+		if i < len(latency.Buckets) {
+			count = latency.Buckets[i]
+		}
+		fmt.Print("lotus_miner_wpost_submission_latency_ratio_bucket { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, ", le=", `"`, bound, `"`, " } ", count, "\n")
+	}
+	fmt.Print("lotus_miner_wpost_submission_latency_ratio_bucket { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, `, le="+Inf" } `, latency.Count, "\n")
+	fmt.Print("lotus_miner_wpost_submission_latency_ratio_sum { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, " } ", latency.Sum, "\n")
+	fmt.Print("lotus_miner_wpost_submission_latency_ratio_count { miner_id=", `"`, minerId, `"`, ", miner_host=", `"`, minerHost, `"`, " } ", latency.Count, "\n")
+}
@@ -4,8 +4,13 @@ go 1.15
 
 require (
 	github.com/filecoin-project/go-address v0.0.5
+	github.com/filecoin-project/go-bitfield v0.2.4
+	github.com/filecoin-project/go-fil-markets v1.1.9
 	github.com/filecoin-project/go-jsonrpc v0.1.4-0.20210217175800-45ea43ac2bec
 	github.com/filecoin-project/go-state-types v0.1.0
 	github.com/filecoin-project/lotus v1.5.3
+	github.com/filecoin-project/specs-actors/v3 v3.0.3
+	github.com/google/uuid v1.1.2
+	github.com/ipfs/go-cid v0.0.7
 	github.com/multiformats/go-multiaddr v0.3.1
 )